@@ -0,0 +1,97 @@
+package videoSummaryGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diarization modes selectable through SummaryConfig.Diarization
+const (
+	// DiarizationTinydiarize uses whisper.cpp's --tinydiarize; it needs a *-tdrz model and marks
+	// speaker turns, not identities, so the segment after each turn is labelled speakerChangeLabel.
+	DiarizationTinydiarize = "tinydiarize"
+	// DiarizationStereo uses whisper.cpp's --diarize, which tells speakers apart by stereo channel.
+	// Audio chunks are kept in stereo for it.
+	DiarizationStereo = "stereo"
+)
+
+// Diarizer labels transcript segments with speakers. It is called with the chunk's WAV file
+// before the file is deleted, so implementations may run their own audio analysis.
+type Diarizer interface {
+	Diarize(audioPath string, segments []TranscriptSegment) ([]TranscriptSegment, error)
+}
+
+var (
+	stereoSpeakerRegex = regexp.MustCompile(`^\(speaker (\d+|\?)\)\s*`)
+	speakerTurnMarker  = "[SPEAKER_TURN]"
+)
+
+// speakerChangeLabel marks a segment where tinydiarize detected a new speaker. Turns carry no
+// identity, so only DiarizationStereo or a Diarizer give labels that stay with a speaker.
+const speakerChangeLabel = "New speaker"
+
+// applySpeakerMarkers turns whisper.cpp's in-text diarization markers into Speaker labels:
+// a leading "(speaker N)" from --diarize, or a trailing "[SPEAKER_TURN]" from --tinydiarize,
+// which labels the next segment speakerChangeLabel. Segments without markers are returned unchanged.
+func applySpeakerMarkers(segments []TranscriptSegment, mode string) []TranscriptSegment {
+	turn := false
+	for i := range segments {
+		text := segments[i].Text
+		switch mode {
+		case DiarizationStereo:
+			if match := stereoSpeakerRegex.FindStringSubmatch(text); match != nil {
+				text = text[len(match[0]):]
+				if n, err := strconv.Atoi(match[1]); err == nil {
+					segments[i].Speaker = speakerLabel(n + 1)
+				} else {
+					segments[i].Speaker = "Speaker ?"
+				}
+			}
+		case DiarizationTinydiarize:
+			if turn {
+				segments[i].Speaker = speakerChangeLabel
+			}
+			turn = strings.HasSuffix(text, speakerTurnMarker)
+			if turn {
+				text = strings.TrimSpace(strings.TrimSuffix(text, speakerTurnMarker))
+			}
+		}
+		segments[i].Text = text
+	}
+	return segments
+}
+
+func speakerLabel(n int) string {
+	return fmt.Sprintf("Speaker %d", n)
+}
+
+// loadSpeakerNames reads a JSON object mapping speaker labels to real names,
+// e.g. {"Speaker 1": "Alice", "Speaker 2": "Bob"}.
+func loadSpeakerNames(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading speaker names file %s: %w", path, err)
+	}
+	var names map[string]string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("error parsing speaker names file %s: %w", path, err)
+	}
+	return names, nil
+}
+
+// renameSpeakers replaces speaker labels that have an entry in names
+func renameSpeakers(segments []TranscriptSegment, names map[string]string) []TranscriptSegment {
+	if len(names) == 0 {
+		return segments
+	}
+	for i := range segments {
+		if name, ok := names[segments[i].Speaker]; ok {
+			segments[i].Speaker = name
+		}
+	}
+	return segments
+}
@@ -0,0 +1,44 @@
+package videoSummaryGo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplySpeakerMarkersTinydiarize(t *testing.T) {
+	segments := []TranscriptSegment{
+		{Text: "Welcome everyone. [SPEAKER_TURN]"},
+		{Text: "Thanks for having me."},
+		{Text: "Glad to be here. [SPEAKER_TURN]"},
+		{Text: "Let's start. [SPEAKER_TURN]"},
+		{Text: "Sure."},
+	}
+	want := []TranscriptSegment{
+		{Text: "Welcome everyone."},
+		{Text: "Thanks for having me.", Speaker: speakerChangeLabel},
+		{Text: "Glad to be here."},
+		{Text: "Let's start.", Speaker: speakerChangeLabel},
+		{Text: "Sure.", Speaker: speakerChangeLabel},
+	}
+	if got := applySpeakerMarkers(segments, DiarizationTinydiarize); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestApplySpeakerMarkersStereo(t *testing.T) {
+	segments := []TranscriptSegment{
+		{Text: "(speaker 0) Hello."},
+		{Text: "(speaker 1) Hi."},
+		{Text: "(speaker ?) Both at once."},
+		{Text: "No marker."},
+	}
+	want := []TranscriptSegment{
+		{Text: "Hello.", Speaker: "Speaker 1"},
+		{Text: "Hi.", Speaker: "Speaker 2"},
+		{Text: "Both at once.", Speaker: "Speaker ?"},
+		{Text: "No marker."},
+	}
+	if got := applySpeakerMarkers(segments, DiarizationStereo); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
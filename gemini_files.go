@@ -0,0 +1,89 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// filePoll configures how waitForFileActive polls an upload
+type filePoll struct {
+	// timeout is the upper bound for Gemini to finish processing the upload
+	timeout time.Duration
+	// initialDelay is the first delay between GetFile polls, doubled up to maxDelay
+	initialDelay time.Duration
+	maxDelay     time.Duration
+}
+
+// defaultFilePoll is used for uploads to Gemini
+var defaultFilePoll = filePoll{timeout: 10 * time.Minute, initialDelay: 2 * time.Second, maxDelay: 30 * time.Second}
+
+// fileService is the part of *genai.Client needed to wait for an upload to become usable.
+// It lets tests substitute a fake file service.
+type fileService interface {
+	GetFile(ctx context.Context, name string) (*genai.File, error)
+}
+
+// ErrFileNotActive is matched (via errors.Is) by every FileProcessingError
+var ErrFileNotActive = errors.New("uploaded file is not active")
+
+// FileProcessingError reports an uploaded file that Gemini failed to process (State is
+// genai.FileStateFailed) or that was still processing when the wait timed out.
+type FileProcessingError struct {
+	Name  string
+	State genai.FileState
+	// Err is the processing error reported by Gemini, or the timeout cause
+	Err error
+}
+
+func (e *FileProcessingError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("file %s is %v: %v", e.Name, e.State, e.Err)
+	}
+	return fmt.Sprintf("file %s is %v", e.Name, e.State)
+}
+
+func (e *FileProcessingError) Unwrap() error { return e.Err }
+
+func (e *FileProcessingError) Is(target error) bool { return target == ErrFileNotActive }
+
+// waitForFileActive polls GetFile with exponential backoff until the uploaded file is ACTIVE.
+// Retryable GetFile errors, such as a 503 or a network reset, are polled through. It returns a
+// *FileProcessingError if the file is FAILED or does not become active within poll.timeout.
+func waitForFileActive(ctx context.Context, files fileService, name string, poll filePoll) (*genai.File, error) {
+	ctx, cancel := context.WithTimeout(ctx, poll.timeout)
+	defer cancel()
+
+	delay := poll.initialDelay
+	state := genai.FileStateUnspecified
+	for {
+		file, err := files.GetFile(ctx, name)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			if !classifyLLMError(ctx, err).Retryable() {
+				return nil, fmt.Errorf("error getting state of file %s: %w", name, err)
+			}
+			logFrom(ctx).Warn("Error getting file state, polling again", "file", name, "error", err)
+		case err == nil && file.State == genai.FileStateActive:
+			return file, nil
+		case err == nil && file.State == genai.FileStateFailed:
+			var processingErr error
+			if file.Error != nil {
+				processingErr = file.Error
+			}
+			return nil, &FileProcessingError{Name: name, State: file.State, Err: processingErr}
+		case err == nil:
+			state = file.State
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &FileProcessingError{Name: name, State: state, Err: fmt.Errorf("not active after %v: %w", poll.timeout, ctx.Err())}
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, poll.maxDelay)
+	}
+}
@@ -0,0 +1,153 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testFilePoll polls in milliseconds, so tests do not sleep for real backoff delays
+var testFilePoll = filePoll{timeout: 5 * time.Second, initialDelay: time.Millisecond, maxDelay: 5 * time.Millisecond}
+
+// fileResponse is one scripted GetFile result
+type fileResponse struct {
+	state genai.FileState
+	err   error
+}
+
+// fakeFileService returns the scripted responses in turn, repeating the last one
+type fakeFileService struct {
+	mu        sync.Mutex
+	responses []fileResponse
+	calls     int
+}
+
+func (f *fakeFileService) GetFile(ctx context.Context, name string) (*genai.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	r := f.responses[min(f.calls, len(f.responses))-1]
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &genai.File{Name: name, State: r.state}, nil
+}
+
+func (f *fakeFileService) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func fileStates(states ...genai.FileState) *fakeFileService {
+	f := &fakeFileService{}
+	for _, s := range states {
+		f.responses = append(f.responses, fileResponse{state: s})
+	}
+	return f
+}
+
+func TestWaitForFileActiveAfterProcessing(t *testing.T) {
+	files := fileStates(genai.FileStateProcessing, genai.FileStateProcessing, genai.FileStateActive)
+	file, err := waitForFileActive(context.Background(), files, "files/abc", testFilePoll)
+	if err != nil {
+		t.Fatalf("waitForFileActive: %v", err)
+	}
+	if file.Name != "files/abc" || file.State != genai.FileStateActive {
+		t.Errorf("got file %s in state %v", file.Name, file.State)
+	}
+	if n := files.callCount(); n != 3 {
+		t.Errorf("GetFile called %d times, want 3", n)
+	}
+}
+
+func TestWaitForFileActiveFailed(t *testing.T) {
+	files := fileStates(genai.FileStateProcessing, genai.FileStateFailed)
+	_, err := waitForFileActive(context.Background(), files, "files/abc", testFilePoll)
+	var processingErr *FileProcessingError
+	if !errors.As(err, &processingErr) {
+		t.Fatalf("got %v, want a *FileProcessingError", err)
+	}
+	if processingErr.Name != "files/abc" || processingErr.State != genai.FileStateFailed {
+		t.Errorf("got %+v", processingErr)
+	}
+	if !errors.Is(err, ErrFileNotActive) {
+		t.Error("error does not match ErrFileNotActive")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Error("a failed file is reported as a timeout")
+	}
+	if n := files.callCount(); n != 2 {
+		t.Errorf("GetFile called %d times, want 2", n)
+	}
+}
+
+func TestWaitForFileActiveTimeout(t *testing.T) {
+	files := fileStates(genai.FileStateProcessing)
+	poll := testFilePoll
+	poll.timeout = 50 * time.Millisecond
+	start := time.Now()
+	_, err := waitForFileActive(context.Background(), files, "files/abc", poll)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, want the 50ms timeout", elapsed)
+	}
+	var processingErr *FileProcessingError
+	if !errors.As(err, &processingErr) {
+		t.Fatalf("got %v, want a *FileProcessingError", err)
+	}
+	if processingErr.State != genai.FileStateProcessing {
+		t.Errorf("got state %v, want the last one seen", processingErr.State)
+	}
+	if !errors.Is(err, ErrFileNotActive) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v does not match ErrFileNotActive and context.DeadlineExceeded", err)
+	}
+	if n := files.callCount(); n < 3 {
+		t.Errorf("GetFile called %d times in 50ms of millisecond polls", n)
+	}
+}
+
+func TestWaitForFileActiveCancelled(t *testing.T) {
+	files := fileStates(genai.FileStateProcessing)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := waitForFileActive(ctx, files, "files/abc", testFilePoll)
+	if !errors.Is(err, ErrFileNotActive) || !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want ErrFileNotActive and context.Canceled", err)
+	}
+}
+
+func TestWaitForFileActiveTransientErrors(t *testing.T) {
+	files := &fakeFileService{responses: []fileResponse{
+		{err: status.Error(codes.Unavailable, "backend unavailable")},
+		{err: context.DeadlineExceeded},
+		{err: status.Error(codes.ResourceExhausted, "slow down")},
+		{state: genai.FileStateActive},
+	}}
+	if _, err := waitForFileActive(context.Background(), files, "files/abc", testFilePoll); err != nil {
+		t.Fatalf("transient errors were not polled through: %v", err)
+	}
+	if n := files.callCount(); n != 4 {
+		t.Errorf("GetFile called %d times, want 4", n)
+	}
+}
+
+func TestWaitForFileActivePermanentError(t *testing.T) {
+	getErr := status.Error(codes.Unauthenticated, "API key not valid")
+	files := &fakeFileService{responses: []fileResponse{{err: getErr}}}
+	_, err := waitForFileActive(context.Background(), files, "files/abc", testFilePoll)
+	if !errors.Is(err, getErr) {
+		t.Fatalf("got %v, want the GetFile error", err)
+	}
+	if errors.Is(err, ErrFileNotActive) {
+		t.Error("a GetFile error is reported as a processing error")
+	}
+	if n := files.callCount(); n != 1 {
+		t.Errorf("GetFile called %d times for a permanent error, want 1", n)
+	}
+}
@@ -0,0 +1,157 @@
+package videoSummaryGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// GenerationConfig tunes the model for one task. Nil or empty fields keep the model's defaults.
+type GenerationConfig struct {
+	Temperature       *float32 `json:"temperature,omitempty"`
+	TopP              *float32 `json:"top_p,omitempty"`
+	TopK              *int32   `json:"top_k,omitempty"`
+	MaxOutputTokens   *int32   `json:"max_output_tokens,omitempty"`
+	SystemInstruction string   `json:"system_instruction,omitempty"`
+	// SafetySettings maps a harm category (harassment, hate_speech, sexually_explicit,
+	// dangerous_content) to a threshold (block_none, block_only_high, block_medium_and_above,
+	// block_low_and_above).
+	SafetySettings map[string]string `json:"safety_settings,omitempty"`
+}
+
+// GenerationConfigs holds a GenerationConfig per task, so OCR-style transcription can run
+// colder than the summaries.
+type GenerationConfigs struct {
+	VideoTranscription GenerationConfig `json:"video_transcription"`
+	ChunkSummary       GenerationConfig `json:"chunk_summary"`
+	FinalSummary       GenerationConfig `json:"final_summary"`
+}
+
+var harmCategoryNames = map[string]genai.HarmCategory{
+	"harassment":        genai.HarmCategoryHarassment,
+	"hate_speech":       genai.HarmCategoryHateSpeech,
+	"sexually_explicit": genai.HarmCategorySexuallyExplicit,
+	"dangerous_content": genai.HarmCategoryDangerousContent,
+}
+
+var harmBlockThresholdNames = map[string]genai.HarmBlockThreshold{
+	"block_none":             genai.HarmBlockNone,
+	"block_only_high":        genai.HarmBlockOnlyHigh,
+	"block_medium_and_above": genai.HarmBlockMediumAndAbove,
+	"block_low_and_above":    genai.HarmBlockLowAndAbove,
+}
+
+// LoadGenerationConfigs reads per-task generation configs from a JSON file, e.g.
+// {"video_transcription": {"temperature": 0.1}, "final_summary": {"max_output_tokens": 8192}}.
+func LoadGenerationConfigs(path string) (GenerationConfigs, error) {
+	var configs GenerationConfigs
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configs, fmt.Errorf("error reading generation config file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return configs, fmt.Errorf("error parsing generation config file %s: %w", path, err)
+	}
+	if err := configs.validate(); err != nil {
+		return configs, fmt.Errorf("invalid generation config file %s: %w", path, err)
+	}
+	return configs, nil
+}
+
+func (g GenerationConfigs) validate() error {
+	for task, cfg := range map[string]GenerationConfig{
+		"video_transcription": g.VideoTranscription,
+		"chunk_summary":       g.ChunkSummary,
+		"final_summary":       g.FinalSummary,
+	} {
+		if _, err := cfg.safetySettings(); err != nil {
+			return fmt.Errorf("%s: %w", task, err)
+		}
+	}
+	return nil
+}
+
+func (g GenerationConfig) safetySettings() ([]*genai.SafetySetting, error) {
+	var settings []*genai.SafetySetting
+	for category, threshold := range g.SafetySettings {
+		c, ok := harmCategoryNames[category]
+		if !ok {
+			return nil, fmt.Errorf("unknown harm category %q", category)
+		}
+		t, ok := harmBlockThresholdNames[threshold]
+		if !ok {
+			return nil, fmt.Errorf("unknown block threshold %q for %s", threshold, category)
+		}
+		settings = append(settings, &genai.SafetySetting{Category: c, Threshold: t})
+	}
+	return settings, nil
+}
+
+// newTaskModel returns a model named name configured with g
+func newTaskModel(client *genai.Client, name string, g GenerationConfig) *genai.GenerativeModel {
+	model := client.GenerativeModel(name)
+	model.Temperature = g.Temperature
+	model.TopP = g.TopP
+	model.TopK = g.TopK
+	model.MaxOutputTokens = g.MaxOutputTokens
+	if g.SystemInstruction != "" {
+		model.SystemInstruction = genai.NewUserContent(genai.Text(g.SystemInstruction))
+	}
+	// Validated up front by GenerationConfigs.validate
+	model.SafetySettings, _ = g.safetySettings()
+	return model
+}
+
+// ModelRoute picks the model for one task
+type ModelRoute struct {
+	// Model is the primary model; SummaryConfig.LLM when empty
+	Model string `json:"model,omitempty"`
+	// Fallback is used when Model is unavailable or out of quota; none when empty
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// ModelRoutes holds a ModelRoute per task, e.g. a cheap fast model for per-chunk transcription
+// and a stronger one for the final summary.
+type ModelRoutes struct {
+	VideoTranscription ModelRoute `json:"video_transcription"`
+	ChunkSummary       ModelRoute `json:"chunk_summary"`
+	FinalSummary       ModelRoute `json:"final_summary"`
+}
+
+// stageModel is a task's primary model and its optional fallback, both configured for the task
+type stageModel struct {
+	name         string
+	primary      *genai.GenerativeModel
+	fallbackName string
+	fallback     *genai.GenerativeModel
+}
+
+func newStageModel(client *genai.Client, defaultModel string, route ModelRoute, g GenerationConfig) *stageModel {
+	m := &stageModel{name: route.Model}
+	if m.name == "" {
+		m.name = defaultModel
+	}
+	m.primary = newTaskModel(client, m.name, g)
+	if route.Fallback != "" && route.Fallback != m.name {
+		m.fallbackName = route.Fallback
+		m.fallback = newTaskModel(client, route.Fallback, g)
+	}
+	return m
+}
+
+// taskModels are the models used for each LLM task of a run
+type taskModels struct {
+	VideoTranscription *stageModel
+	ChunkSummary       *stageModel
+	FinalSummary       *stageModel
+}
+
+func newTaskModels(client *genai.Client, cfg *SummaryConfig) taskModels {
+	return taskModels{
+		VideoTranscription: newStageModel(client, cfg.LLM, cfg.Models.VideoTranscription, cfg.Generation.VideoTranscription),
+		ChunkSummary:       newStageModel(client, cfg.LLM, cfg.Models.ChunkSummary, cfg.Generation.ChunkSummary),
+		FinalSummary:       newStageModel(client, cfg.LLM, cfg.Models.FinalSummary, cfg.Generation.FinalSummary),
+	}
+}
@@ -0,0 +1,224 @@
+package videoSummaryGo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// folderScan selects which videos of a folder are summarized
+type folderScan struct {
+	include, exclude []string
+	// maxDepth is how many levels are searched, 1 being the folder itself; 0 is unlimited
+	maxDepth int
+	// force keeps videos that an earlier run already summarized
+	force bool
+}
+
+func (cfg *SummaryConfig) folderScan() folderScan {
+	return folderScan{include: cfg.Include, exclude: cfg.Exclude, maxDepth: cfg.MaxDepth, force: cfg.Force}
+}
+
+// splitPatterns splits a comma-separated flag value into glob patterns, checking their syntax
+func splitPatterns(value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchesAny reports whether a pattern matches the entry's name or its slash-separated path
+// relative to the folder
+func matchesAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// findVideos returns the absolute path of inputPath if it is a video, or of the videos under it
+// that scan selects if it is a folder. Folder entries that cannot be read are skipped and returned
+// as failures.
+func findVideos(logger *slog.Logger, inputPath string, scan folderScan) (videoPaths []string, failures []error, err error) {
+	// Ensure inputPath is absolute BEFORE stat check
+	absInputPath, err := filepath.Abs(inputPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error converting input path %s to absolute: %w", inputPath, err)
+	}
+
+	fileInfo, err := os.Stat(absInputPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error accessing input path %s: %w", absInputPath, err)
+	}
+
+	// Use absInputPath consistently from now on
+	inputPath = absInputPath // Update inputPath to the absolute version
+
+	if !fileInfo.IsDir() {
+		logger.Info("Processing single file", "path", inputPath) // Already absolute
+		if !IsVideoFile(inputPath) {
+			return nil, nil, fmt.Errorf("input path %s is not a video file", inputPath)
+		}
+		return []string{inputPath}, nil, nil
+	}
+
+	logger.Info("Processing folder", "path", inputPath)
+	skipped := 0
+	err = filepath.WalkDir(inputPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == inputPath {
+				return err
+			}
+			logger.Warn("Skipping unreadable path", "path", path, "error", err)
+			failures = append(failures, fmt.Errorf("error reading %s: %w", path, err))
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path == inputPath {
+			return nil
+		}
+		rel, err := filepath.Rel(inputPath, path)
+		if err != nil {
+			return err
+		}
+		depth := strings.Count(filepath.ToSlash(rel), "/") + 1
+		if d.IsDir() {
+			if (scan.maxDepth > 0 && depth >= scan.maxDepth) || matchesAny(scan.exclude, rel) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !IsVideoFile(path) || matchesAny(scan.exclude, rel) || (len(scan.include) > 0 && !matchesAny(scan.include, rel)) {
+			return nil
+		}
+		if !scan.force {
+			if reason := alreadySummarized(path); reason != "" {
+				logger.Info("Skipping already summarized video", "path", path, "reason", reason)
+				skipped++
+				return nil
+			}
+		}
+		videoPaths = append(videoPaths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error walking directory %s: %w", inputPath, err)
+	}
+	if skipped > 0 {
+		logger.Info("Skipped videos summarized by an earlier run; use --force to summarize them again", "skipped", skipped)
+	}
+	return videoPaths, failures, nil
+}
+
+// summaryPath returns the summary file written for videoPath
+func summaryPath(videoPath string) string {
+	baseName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	return filepath.Join(filepath.Dir(videoPath), baseName+"_output.txt")
+}
+
+// findOutput returns the output file path written for videoPath, or the same file in the folder
+// named after the video, where file-split.sh moves the outputs
+func findOutput(videoPath, path string) (string, os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err == nil {
+		return path, info, nil
+	}
+	baseName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	split := filepath.Join(filepath.Dir(path), baseName, filepath.Base(path))
+	if info, splitErr := os.Stat(split); splitErr == nil {
+		return split, info, nil
+	}
+	return "", nil, err
+}
+
+// alreadySummarized returns why videoPath needs no new summary, or "" if it does. A video is done
+// when its last run succeeded and the video is unchanged since, judged by its size and
+// modification time or else by its content hash. Without a run report, from older versions, a
+// summary newer than the video counts as done. The outputs may also be in a folder named after
+// the video, as file-split.sh leaves them.
+func alreadySummarized(videoPath string) string {
+	video, err := os.Stat(videoPath)
+	if err != nil {
+		return ""
+	}
+	_, summary, err := findOutput(videoPath, summaryPath(videoPath))
+	if err != nil || summary.Size() == 0 {
+		return ""
+	}
+	var data []byte
+	reportFile, _, err := findOutput(videoPath, reportPath(videoPath))
+	if err == nil {
+		data, err = os.ReadFile(reportFile)
+	}
+	if err != nil {
+		if summary.ModTime().After(video.ModTime()) {
+			return "summary is newer than the video"
+		}
+		return ""
+	}
+	var report VideoReport
+	if err := json.Unmarshal(data, &report); err != nil || report.Error != "" || report.SourceSHA256 == "" {
+		return ""
+	}
+	if report.SourceSize != video.Size() {
+		return ""
+	}
+	if report.SourceModTime.Equal(video.ModTime()) {
+		return "unchanged since the last run"
+	}
+	// Touched or copied, e.g. by a sync tool: compare the content
+	if hash, err := fileSHA256(videoPath); err == nil && hash == report.SourceSHA256 {
+		return "same content as the last run"
+	}
+	return ""
+}
+
+// setSource records the size, modification time and content hash of the video in the report, so
+// a later run can tell the video was already summarized
+func (r *VideoReport) setSource(videoPath string) error {
+	info, err := os.Stat(videoPath)
+	if err != nil {
+		return err
+	}
+	hash, err := fileSHA256(videoPath)
+	if err != nil {
+		return err
+	}
+	r.SourceSize, r.SourceModTime, r.SourceSHA256 = info.Size(), info.ModTime(), hash
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("error hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
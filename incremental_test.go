@@ -0,0 +1,149 @@
+package videoSummaryGo
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeReport writes the run report of an earlier run of video; set records the video as summarized
+func writeReport(t *testing.T, video string, set func(*VideoReport)) {
+	t.Helper()
+	report := &VideoReport{VideoPath: video}
+	if err := report.setSource(video); err != nil {
+		t.Fatal(err)
+	}
+	if set != nil {
+		set(report)
+	}
+	if err := report.write(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// summarizedTree builds a folder of videos in every state an earlier run can leave them in
+func summarizedTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	path := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+	old := time.Now().Add(-time.Hour)
+	for _, rel := range []string{"new.mp4", "drafts/draft.mp4", "drafts/deep/raw.mkv", "notes.txt"} {
+		writeFile(t, path(rel), "video "+rel)
+	}
+
+	// Summarized before run reports existed
+	writeFile(t, path("legacy.mp4"), "legacy video")
+	os.Chtimes(path("legacy.mp4"), old, old)
+	writeFile(t, path("legacy_output.txt"), "summary")
+	// Its summary is older than the video, which was replaced since
+	writeFile(t, path("stale_output.txt"), "summary")
+	os.Chtimes(path("stale_output.txt"), old, old)
+	writeFile(t, path("stale.mp4"), "replaced video")
+
+	// Outputs moved into a per-video folder by file-split.sh
+	writeFile(t, path("split.mp4"), "split video")
+	writeFile(t, path("split/split_output.txt"), "summary")
+	writeReport(t, path("split.mp4"), nil)
+	os.Rename(path("split_report.json"), path("split/split_report.json"))
+
+	// Unchanged since a run with a report
+	writeFile(t, path("unchanged.mp4"), "unchanged video")
+	writeFile(t, path("unchanged_output.txt"), "summary")
+	writeReport(t, path("unchanged.mp4"), nil)
+
+	// Touched by a sync tool: same content, new modification time
+	writeFile(t, path("touched.mp4"), "touched video")
+	writeFile(t, path("touched_output.txt"), "summary")
+	writeReport(t, path("touched.mp4"), nil)
+	os.Chtimes(path("touched.mp4"), old, old)
+
+	// Re-recorded with the same size
+	writeFile(t, path("edited.mp4"), "edited video")
+	writeFile(t, path("edited_output.txt"), "summary")
+	writeReport(t, path("edited.mp4"), nil)
+	writeFile(t, path("edited.mp4"), "EDITED VIDEO")
+	os.Chtimes(path("edited.mp4"), old, old)
+
+	// The last run failed
+	writeFile(t, path("failed.mp4"), "failed video")
+	writeFile(t, path("failed_output.txt"), "partial summary")
+	writeReport(t, path("failed.mp4"), func(r *VideoReport) { r.Error = "upload failed" })
+	return root
+}
+
+func TestFindVideos(t *testing.T) {
+	root := summarizedTree(t)
+	pending := []string{"drafts/deep/raw.mkv", "drafts/draft.mp4", "edited.mp4", "failed.mp4", "new.mp4", "stale.mp4"}
+	all := []string{"drafts/deep/raw.mkv", "drafts/draft.mp4", "edited.mp4", "failed.mp4", "legacy.mp4", "new.mp4", "split.mp4", "stale.mp4", "touched.mp4", "unchanged.mp4"}
+	tests := []struct {
+		name string
+		scan folderScan
+		want []string
+	}{
+		{"skips summarized videos", folderScan{}, pending},
+		{"force", folderScan{force: true}, all},
+		{"max depth 1", folderScan{maxDepth: 1}, []string{"edited.mp4", "failed.mp4", "new.mp4", "stale.mp4"}},
+		{"max depth 2", folderScan{maxDepth: 2}, []string{"drafts/draft.mp4", "edited.mp4", "failed.mp4", "new.mp4", "stale.mp4"}},
+		{"include by name", folderScan{include: []string{"*.mkv"}}, []string{"drafts/deep/raw.mkv"}},
+		{"include by relative path", folderScan{include: []string{"drafts/*"}}, []string{"drafts/draft.mp4"}},
+		{"include with force", folderScan{include: []string{"s*.mp4", "t*.mp4"}, force: true}, []string{"split.mp4", "stale.mp4", "touched.mp4"}},
+		{"exclude folder", folderScan{exclude: []string{"drafts"}}, []string{"edited.mp4", "failed.mp4", "new.mp4", "stale.mp4"}},
+		{"exclude by name", folderScan{exclude: []string{"*ed.mp4", "*.mkv"}}, []string{"drafts/draft.mp4", "new.mp4", "stale.mp4"}},
+		{"exclude wins over include", folderScan{include: []string{"*.mp4"}, exclude: []string{"new.mp4"}}, []string{"drafts/draft.mp4", "edited.mp4", "failed.mp4", "stale.mp4"}},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			videos, failures, err := findVideos(logger, root, tt.scan)
+			if err != nil || len(failures) != 0 {
+				t.Fatalf("findVideos: %v, %v", err, failures)
+			}
+			var got []string
+			for _, v := range videos {
+				rel, _ := filepath.Rel(root, v)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlreadySummarizedReasons(t *testing.T) {
+	root := summarizedTree(t)
+	tests := map[string]string{
+		"legacy.mp4":    "summary is newer than the video",
+		"split.mp4":     "unchanged since the last run",
+		"unchanged.mp4": "unchanged since the last run",
+		"touched.mp4":   "same content as the last run",
+		"stale.mp4":     "",
+		"edited.mp4":    "",
+		"failed.mp4":    "",
+		"new.mp4":       "",
+	}
+	for name, want := range tests {
+		if got := alreadySummarized(filepath.Join(root, name)); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFindVideosSingleFile(t *testing.T) {
+	root := summarizedTree(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// A video given as the input is summarized even if it was before
+	videos, _, err := findVideos(logger, filepath.Join(root, "unchanged.mp4"), folderScan{})
+	if err != nil || len(videos) != 1 {
+		t.Errorf("got %v, %v", videos, err)
+	}
+	if _, _, err := findVideos(logger, filepath.Join(root, "notes.txt"), folderScan{}); err == nil {
+		t.Error("a text file was accepted as a video")
+	}
+}
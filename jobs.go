@@ -0,0 +1,356 @@
+package videoSummaryGo
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// JobStatus is the state of a summary job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Where a job's video comes from
+const (
+	JobInputUpload = "upload"
+	JobInputURL    = "url"
+	JobInputPath   = "path"
+)
+
+var (
+	// ErrJobNotFound is returned by a JobStore for an unknown job ID
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobState is returned when a job cannot be cancelled or retried in its current status
+	ErrJobState = errors.New("job is in the wrong state")
+)
+
+// DefaultJobRetryPolicy is used when ServerConfig.Retry is left zero: a failed job runs up to
+// twice more, after a random backoff of 30s to one and then one to two minutes
+var DefaultJobRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  time.Minute,
+	MaxDelay:   time.Hour,
+}
+
+// Job is a summary request submitted to the server
+type Job struct {
+	ID     string    `json:"id"`
+	Status JobStatus `json:"status"`
+	// InputKind is one of the JobInput* kinds; Input is the URL, or the server path of the
+	// uploaded or named video (a folder of videos for JobInputPath)
+	InputKind  string     `json:"input_kind"`
+	Input      string     `json:"input"`
+	Options    JobOptions `json:"options"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  time.Time  `json:"started_at,omitzero"`
+	FinishedAt time.Time  `json:"finished_at,omitzero"`
+	// Attempts counts the runs started so far. A failed run is queued again until the server's
+	// retry policy is used up, not before NextAttemptAt.
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitzero"`
+	// Error is why the last run failed
+	Error   string      `json:"error,omitempty"`
+	Results []JobResult `json:"results,omitempty"`
+}
+
+// JobOptions are the per-job overrides of the server's SummaryConfig
+type JobOptions struct {
+	// Prompt is extra instructions for the summary (SummaryConfig.InputFromUser)
+	Prompt             string `json:"prompt,omitempty"`
+	Model              string `json:"model,omitempty"`
+	ChunkDuration      int    `json:"chunk_duration,omitempty"`
+	WhisperLanguage    string `json:"whisper_language,omitempty"`
+	Translate          bool   `json:"translate,omitempty"`
+	SummaryLanguage    string `json:"summary_language,omitempty"`
+	VideoOnly          bool   `json:"video_only,omitempty"`
+	VideoTranscription string `json:"video_transcription,omitempty"`
+	OCR                string `json:"ocr,omitempty"`
+	Diarization        string `json:"diarization,omitempty"`
+	ChunkSummaries     bool   `json:"chunk_summaries,omitempty"`
+	// Include, Exclude, MaxDepth and Force select the videos of a folder, as in SummaryConfig
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	MaxDepth int      `json:"max_depth,omitempty"`
+	Force    bool     `json:"force,omitempty"`
+}
+
+// apply overrides cfg with the options that are set
+func (o JobOptions) apply(cfg *SummaryConfig) {
+	if o.Prompt != "" {
+		cfg.InputFromUser = o.Prompt
+	}
+	if o.Model != "" {
+		cfg.LLM = o.Model
+	}
+	if o.ChunkDuration > 0 {
+		cfg.ChunkDuration = o.ChunkDuration
+	}
+	if o.WhisperLanguage != "" {
+		cfg.WhisperLanguage = o.WhisperLanguage
+	}
+	if o.SummaryLanguage != "" {
+		cfg.SummaryLanguage = o.SummaryLanguage
+	}
+	if o.VideoTranscription != "" {
+		cfg.VideoTranscription = o.VideoTranscription
+	}
+	if o.OCR != "" {
+		cfg.VideoOCR = o.OCR
+	}
+	if o.Diarization != "" {
+		cfg.Diarization = o.Diarization
+	}
+	cfg.WhisperTranslate = cfg.WhisperTranslate || o.Translate
+	cfg.VideoOnly = cfg.VideoOnly || o.VideoOnly
+	cfg.ChunkSummaries = cfg.ChunkSummaries || o.ChunkSummaries
+	if len(o.Include) > 0 {
+		cfg.Include = o.Include
+	}
+	if len(o.Exclude) > 0 {
+		cfg.Exclude = o.Exclude
+	}
+	if o.MaxDepth > 0 {
+		cfg.MaxDepth = o.MaxDepth
+	}
+	cfg.Force = cfg.Force || o.Force
+}
+
+// JobResult is the outcome of one video of a job
+type JobResult struct {
+	VideoPath   string      `json:"video_path"`
+	VideoIndex  int         `json:"video_index"`
+	Files       OutputFiles `json:"files"`
+	StartedAt   time.Time   `json:"started_at,omitzero"`
+	FinishedAt  time.Time   `json:"finished_at,omitzero"`
+	Error       string      `json:"error,omitempty"`
+	ChunkErrors []string    `json:"chunk_errors,omitempty"`
+}
+
+func newJobResults(results []*Result) []JobResult {
+	jobResults := make([]JobResult, 0, len(results))
+	for _, r := range results {
+		jr := JobResult{VideoPath: r.VideoPath, VideoIndex: r.VideoIndex, Files: r.Files}
+		if r.Report != nil {
+			jr.StartedAt, jr.FinishedAt = r.Report.StartedAt, r.Report.FinishedAt
+		}
+		if r.Err != nil {
+			jr.Error = r.Err.Error()
+		}
+		for _, e := range r.Errors {
+			jr.ChunkErrors = append(jr.ChunkErrors, e.Error())
+		}
+		jobResults = append(jobResults, jr)
+	}
+	return jobResults
+}
+
+// JobStore persists jobs, so the queue survives a server restart. Implementations must be safe
+// for concurrent use; Get and List return copies the caller may modify.
+type JobStore interface {
+	Create(job *Job) error
+	Get(id string) (*Job, error)
+	// List returns every job, oldest first
+	List() ([]*Job, error)
+	// Update saves job if its stored status is still from, and returns an ErrJobState error
+	// otherwise, so a change made meanwhile by another worker or process is not overwritten
+	Update(job *Job, from JobStatus) error
+	// Claim marks the oldest queued job that is due at now as running, counts the attempt and
+	// returns it, or returns nil when there is none. Each job is claimed by one caller only.
+	Claim(now time.Time) (*Job, error)
+	Close() error
+}
+
+// jobStorePath is where the server and the jobs command keep the queue
+func jobStorePath(dataDir string) string {
+	return filepath.Join(dataDir, "jobs.db")
+}
+
+const defaultDataDir = "videoSummaryData"
+
+// cancelJob cancels a queued job, or marks a running one cancelled for its worker to stop
+func cancelJob(store JobStore, id string) (*Job, error) {
+	job, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	from := job.Status
+	switch job.Status {
+	case JobQueued:
+		job.Status, job.Error, job.FinishedAt, job.NextAttemptAt = JobCancelled, "cancelled", time.Now(), time.Time{}
+	case JobRunning:
+		job.Status = JobCancelled
+	default:
+		return nil, fmt.Errorf("%w: job %s is already %s", ErrJobState, job.ID, job.Status)
+	}
+	if err := store.Update(job, from); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// retryJob queues a failed or cancelled job again, with a fresh set of retries. A job cancelled
+// while running has no FinishedAt until its worker has stopped, and cannot be retried before.
+func retryJob(store JobStore, id string) (*Job, error) {
+	job, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != JobFailed && job.Status != JobCancelled {
+		return nil, fmt.Errorf("%w: job %s is %s", ErrJobState, job.ID, job.Status)
+	}
+	if job.FinishedAt.IsZero() {
+		return nil, fmt.Errorf("%w: job %s is still stopping", ErrJobState, job.ID)
+	}
+	from := job.Status
+	job.Status, job.Error, job.Attempts, job.Results = JobQueued, "", 0, nil
+	job.StartedAt, job.FinishedAt, job.NextAttemptAt = time.Time{}, time.Time{}, time.Time{}
+	if err := store.Update(job, from); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// jobsMain is the "jobs" command: manage the queue of a server sharing the same --data-dir
+func jobsMain(args []string) {
+	fs := flag.NewFlagSet("jobs", flag.ExitOnError)
+	dataDir := fs.String("data-dir", defaultDataDir, "directory holding the job queue, as passed to serve")
+	fs.Usage = func() {
+		fmt.Println("Usage: program jobs [--data-dir DIR] list | add [options] <video_or_folder_or_youtube_url>... | retry <id>... | cancel <id>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	store, err := NewSQLiteJobStore(jobStorePath(*dataDir))
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	defer store.Close()
+	switch command, rest := fs.Arg(0), fs.Args()[1:]; command {
+	case "list":
+		err = listJobs(os.Stdout, store)
+	case "add":
+		err = addJobs(os.Stdout, store, rest)
+	case "retry", "cancel":
+		update := retryJob
+		if command == "cancel" {
+			update = cancelJob
+		}
+		var errs []error
+		for _, id := range rest {
+			job, err := update(store, id)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			fmt.Printf("%s %s\n", job.ID, job.Status)
+		}
+		err = errors.Join(errs...)
+	default:
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err != nil {
+		store.Close()
+		log.Fatalf("%v\n", err)
+	}
+}
+
+func listJobs(w io.Writer, store JobStore) error {
+	jobs, err := store.List()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tStatus\tAttempts\tCreated\tDuration\tInput\tError")
+	for _, job := range jobs {
+		status := string(job.Status)
+		if job.Status == JobQueued && job.NextAttemptAt.After(time.Now()) {
+			status += " (retry at " + job.NextAttemptAt.Format(time.TimeOnly) + ")"
+		}
+		duration := "-"
+		if !job.FinishedAt.IsZero() && !job.StartedAt.IsZero() {
+			duration = job.FinishedAt.Sub(job.StartedAt).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", job.ID, status, job.Attempts, job.CreatedAt.Format(time.DateTime), duration, job.Input, job.Error)
+	}
+	return tw.Flush()
+}
+
+// addJobs queues one job per video named in args, so a folder's videos are tracked and retried
+// one by one
+func addJobs(w io.Writer, store JobStore, args []string) error {
+	fs := flag.NewFlagSet("jobs add", flag.ExitOnError)
+	var options JobOptions
+	fs.StringVar(&options.Prompt, "prompt", "", "extra instructions for the summary")
+	fs.StringVar(&options.Model, "model", "", "Gemini model, instead of the server's")
+	fs.IntVar(&options.ChunkDuration, "chunk-duration", 0, "chunk length in seconds, instead of the server's")
+	fs.StringVar(&options.WhisperLanguage, "whisper-language", "", "spoken language, or auto")
+	fs.BoolVar(&options.Translate, "translate", false, "have whisper translate the audio transcript to English")
+	fs.StringVar(&options.SummaryLanguage, "summary-language", "", "language the summary is written in")
+	fs.BoolVar(&options.VideoOnly, "video-only", false, "summarize only the text shown in the video")
+	fs.StringVar(&options.VideoTranscription, "video-transcription", "", "upload or keyframes")
+	fs.StringVar(&options.OCR, "ocr", "", "fallback or hybrid")
+	fs.StringVar(&options.Diarization, "diarize", "", "tinydiarize or stereo")
+	fs.BoolVar(&options.ChunkSummaries, "chunk-summaries", false, "summarize every chunk before the final summary")
+	include := fs.String("include", "", "comma-separated glob patterns; only videos of a folder matching one are queued")
+	exclude := fs.String("exclude", "", "comma-separated glob patterns of videos and subfolders to leave out")
+	maxDepth := fs.Int("max-depth", 0, "how many folder levels to search for videos, 1 being the folder itself; 0 is unlimited")
+	force := fs.Bool("force", false, "also queue videos an earlier run already summarized")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("jobs add needs a video, folder or YouTube URL")
+	}
+	scan := folderScan{maxDepth: *maxDepth, force: *force}
+	var err error
+	if scan.include, err = splitPatterns(*include); err != nil {
+		return err
+	}
+	if scan.exclude, err = splitPatterns(*exclude); err != nil {
+		return err
+	}
+
+	logger := slog.Default()
+	var errs []error
+	add := func(kind, input string) {
+		job := &Job{ID: newJobID(), Status: JobQueued, InputKind: kind, Input: input, Options: options, CreatedAt: time.Now()}
+		if err := store.Create(job); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		fmt.Fprintf(w, "%s %s\n", job.ID, job.Input)
+	}
+	for _, input := range fs.Args() {
+		if isValidYoutubeURL(input) {
+			add(JobInputURL, input)
+			continue
+		}
+		videoPaths, failures, err := findVideos(logger, input, scan)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, failures...)
+		for _, path := range videoPaths {
+			add(JobInputPath, path)
+		}
+	}
+	return errors.Join(errs...)
+}
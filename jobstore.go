@@ -0,0 +1,206 @@
+package videoSummaryGo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteJobStore keeps jobs in a SQLite database. Several processes may share it, e.g. the
+// server and the jobs command.
+type sqliteJobStore struct {
+	db *sql.DB
+}
+
+const jobSchema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id              TEXT PRIMARY KEY,
+	status          TEXT NOT NULL,
+	input_kind      TEXT NOT NULL,
+	input           TEXT NOT NULL,
+	options         TEXT NOT NULL,
+	created_at      INTEGER NOT NULL,
+	started_at      INTEGER,
+	finished_at     INTEGER,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at INTEGER,
+	error           TEXT NOT NULL DEFAULT '',
+	results         TEXT
+);
+CREATE INDEX IF NOT EXISTS jobs_status ON jobs (status, created_at);
+`
+
+const jobColumns = `id, status, input_kind, input, options, created_at, started_at, finished_at, attempts, next_attempt_at, error, results`
+
+// NewSQLiteJobStore opens the job database at path, creating it if needed
+func NewSQLiteJobStore(path string) (JobStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating job store directory: %w", err)
+	}
+	// WAL lets the jobs command read while the server writes; busy_timeout makes writers from
+	// different processes wait for each other instead of failing
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening job store %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(jobSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating job store %s: %w", path, err)
+	}
+	return &sqliteJobStore{db: db}, nil
+}
+
+func (s *sqliteJobStore) Create(job *Job) error {
+	options, results, err := encodeJobFields(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO jobs (`+jobColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Status, job.InputKind, job.Input, options, job.CreatedAt.UnixNano(), unixNanos(job.StartedAt),
+		unixNanos(job.FinishedAt), job.Attempts, unixNanos(job.NextAttemptAt), job.Error, results)
+	if err != nil {
+		return fmt.Errorf("error creating job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (s *sqliteJobStore) Get(id string) (*Job, error) {
+	job, err := scanJob(s.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+func (s *sqliteJobStore) List() ([]*Job, error) {
+	rows, err := s.db.Query(`SELECT ` + jobColumns + ` FROM jobs ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	defer rows.Close()
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error listing jobs: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (s *sqliteJobStore) Update(job *Job, from JobStatus) error {
+	options, results, err := encodeJobFields(job)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`UPDATE jobs SET status = ?, input_kind = ?, input = ?, options = ?, started_at = ?, finished_at = ?,
+		attempts = ?, next_attempt_at = ?, error = ?, results = ? WHERE id = ? AND status = ?`,
+		job.Status, job.InputKind, job.Input, options, unixNanos(job.StartedAt), unixNanos(job.FinishedAt),
+		job.Attempts, unixNanos(job.NextAttemptAt), job.Error, results, job.ID, from)
+	if err != nil {
+		return fmt.Errorf("error updating job %s: %w", job.ID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		stored, err := s.Get(job.ID)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: job %s is %s, not %s", ErrJobState, job.ID, stored.Status, from)
+	}
+	return nil
+}
+
+func (s *sqliteJobStore) Claim(now time.Time) (*Job, error) {
+	// A single statement, so two workers (or two processes) never claim the same job
+	job, err := scanJob(s.db.QueryRow(`UPDATE jobs SET status = ?, started_at = ?, finished_at = NULL, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM jobs WHERE status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+			ORDER BY created_at, id LIMIT 1
+		)
+		RETURNING `+jobColumns, JobRunning, now.UnixNano(), JobQueued, now.UnixNano()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming a job: %w", err)
+	}
+	return job, nil
+}
+
+func (s *sqliteJobStore) Close() error {
+	return s.db.Close()
+}
+
+func encodeJobFields(job *Job) (options string, results sql.NullString, err error) {
+	data, err := json.Marshal(job.Options)
+	if err != nil {
+		return "", results, fmt.Errorf("error encoding options of job %s: %w", job.ID, err)
+	}
+	options = string(data)
+	if job.Results != nil {
+		data, err := json.Marshal(job.Results)
+		if err != nil {
+			return "", results, fmt.Errorf("error encoding results of job %s: %w", job.ID, err)
+		}
+		results = sql.NullString{String: string(data), Valid: true}
+	}
+	return options, results, nil
+}
+
+// scanJob reads a row of jobColumns
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	var (
+		job                                Job
+		options                            string
+		results                            sql.NullString
+		createdAt                          int64
+		startedAt, finishedAt, nextAttempt sql.NullInt64
+	)
+	err := row.Scan(&job.ID, &job.Status, &job.InputKind, &job.Input, &options, &createdAt, &startedAt, &finishedAt,
+		&job.Attempts, &nextAttempt, &job.Error, &results)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &job.Options); err != nil {
+		return nil, fmt.Errorf("error parsing options of job %s: %w", job.ID, err)
+	}
+	if results.Valid {
+		if err := json.Unmarshal([]byte(results.String), &job.Results); err != nil {
+			return nil, fmt.Errorf("error parsing results of job %s: %w", job.ID, err)
+		}
+	}
+	job.CreatedAt = time.Unix(0, createdAt)
+	job.StartedAt = nullTime(startedAt)
+	job.FinishedAt = nullTime(finishedAt)
+	job.NextAttemptAt = nullTime(nextAttempt)
+	return &job, nil
+}
+
+// unixNanos stores the zero time as NULL
+func unixNanos(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func nullTime(n sql.NullInt64) time.Time {
+	if !n.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, n.Int64)
+}
@@ -0,0 +1,245 @@
+package videoSummaryGo
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestJobStore(t *testing.T, path string) JobStore {
+	t.Helper()
+	store, err := NewSQLiteJobStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// queueJob creates a queued job created at createdAt
+func queueJob(t *testing.T, store JobStore, id string, createdAt time.Time) *Job {
+	t.Helper()
+	job := &Job{ID: id, Status: JobQueued, InputKind: JobInputPath, Input: "/videos/" + id, CreatedAt: createdAt}
+	if err := store.Create(job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestSQLiteJobStoreRoundTrip(t *testing.T) {
+	store := newTestJobStore(t, filepath.Join(t.TempDir(), "jobs.db"))
+	now := time.Now()
+	job := &Job{
+		ID: "a", Status: JobFailed, InputKind: JobInputURL, Input: "https://example.com/talk",
+		Options:   JobOptions{Prompt: "Keep it short", Include: []string{"*.mp4"}, MaxDepth: 2},
+		CreatedAt: now.Add(-time.Hour), StartedAt: now.Add(-time.Minute), FinishedAt: now,
+		Attempts: 3, Error: "download failed",
+		Results: []JobResult{{VideoPath: "/videos/talk.mp4", VideoIndex: 1, Error: "no audio"}},
+	}
+	if err := store.Create(job); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(job.CreatedAt) || !got.StartedAt.Equal(job.StartedAt) || !got.FinishedAt.Equal(job.FinishedAt) || !got.NextAttemptAt.IsZero() {
+		t.Errorf("got times %v %v %v %v", got.CreatedAt, got.StartedAt, got.FinishedAt, got.NextAttemptAt)
+	}
+	got.CreatedAt, got.StartedAt, got.FinishedAt = job.CreatedAt, job.StartedAt, job.FinishedAt
+	if !reflect.DeepEqual(got, job) {
+		t.Errorf("got %+v, want %+v", got, job)
+	}
+
+	queueJob(t, store, "b", now.Add(-2*time.Hour))
+	jobs, err := store.List()
+	if err != nil || len(jobs) != 2 || jobs[0].ID != "b" || jobs[1].ID != "a" {
+		t.Errorf("List returned %v, %v; want b then a", jobs, err)
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("got %v for an unknown job, want ErrJobNotFound", err)
+	}
+}
+
+func TestSQLiteJobStoreClaim(t *testing.T) {
+	store := newTestJobStore(t, filepath.Join(t.TempDir(), "jobs.db"))
+	now := time.Now()
+	queueJob(t, store, "newer", now.Add(-time.Minute))
+	older := queueJob(t, store, "older", now.Add(-time.Hour))
+	// Waiting for its retry, so claimed last even though it is the oldest
+	older.NextAttemptAt = now.Add(time.Minute)
+	if err := store.Update(older, JobQueued); err != nil {
+		t.Fatal(err)
+	}
+	done := &Job{ID: "done", Status: JobSucceeded, CreatedAt: now.Add(-2 * time.Hour)}
+	if err := store.Create(done); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := store.Claim(now)
+	if err != nil || job == nil || job.ID != "newer" {
+		t.Fatalf("first claim got %v, %v; want the due job", job, err)
+	}
+	if job.Status != JobRunning || job.Attempts != 1 || !job.StartedAt.Equal(now) {
+		t.Errorf("claimed job is %s, attempt %d, started %v", job.Status, job.Attempts, job.StartedAt)
+	}
+	if job, err := store.Claim(now); job != nil || err != nil {
+		t.Errorf("claimed %v, %v before its retry is due", job, err)
+	}
+	job, err = store.Claim(now.Add(time.Minute))
+	if err != nil || job == nil || job.ID != "older" {
+		t.Fatalf("claim at the retry time got %v, %v", job, err)
+	}
+	if job.Attempts != 1 {
+		t.Errorf("got attempt %d, want 1", job.Attempts)
+	}
+}
+
+func TestSQLiteJobStoreConcurrentClaims(t *testing.T) {
+	// Two stores on one file, as the server and another process would open it
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	stores := []JobStore{newTestJobStore(t, path), newTestJobStore(t, path)}
+	now := time.Now()
+	const jobCount = 20
+	for i := range jobCount {
+		queueJob(t, stores[0], newJobID(), now.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = map[string]int{}
+		wg      sync.WaitGroup
+	)
+	for i := range 2 * jobCount {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job, err := stores[i%2].Claim(now.Add(time.Second))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if job != nil {
+				mu.Lock()
+				claimed[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(claimed) != jobCount {
+		t.Errorf("%d of %d jobs were claimed", len(claimed), jobCount)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %s was claimed %d times", id, n)
+		}
+	}
+}
+
+func TestSQLiteJobStoreStaleUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	server, command := newTestJobStore(t, path), newTestJobStore(t, path)
+	queueJob(t, server, "a", time.Now())
+	job, err := server.Claim(time.Now())
+	if err != nil || job == nil {
+		t.Fatalf("claim: %v, %v", job, err)
+	}
+
+	// "jobs cancel" marks it cancelled while the worker runs it
+	if _, err := cancelJob(command, "a"); err != nil {
+		t.Fatal(err)
+	}
+	job.Status, job.FinishedAt = JobSucceeded, time.Now()
+	err = server.Update(job, JobRunning)
+	if !errors.Is(err, ErrJobState) {
+		t.Fatalf("stale update got %v, want ErrJobState", err)
+	}
+	if stored, _ := server.Get("a"); stored.Status != JobCancelled || !stored.FinishedAt.IsZero() {
+		t.Errorf("stale update overwrote the job: %+v", stored)
+	}
+	if err := server.Update(job, JobCancelled); err != nil {
+		t.Errorf("update with the current status: %v", err)
+	}
+	if err := server.Update(&Job{ID: "missing"}, JobQueued); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("updating an unknown job got %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestCancelAndRetryJob(t *testing.T) {
+	store := newTestJobStore(t, filepath.Join(t.TempDir(), "jobs.db"))
+	queued := queueJob(t, store, "queued", time.Now())
+	queued.NextAttemptAt = time.Now().Add(time.Hour)
+	store.Update(queued, JobQueued)
+
+	job, err := cancelJob(store, "queued")
+	if err != nil || job.Status != JobCancelled || job.FinishedAt.IsZero() || !job.NextAttemptAt.IsZero() {
+		t.Fatalf("cancelling a queued job got %+v, %v", job, err)
+	}
+	if _, err := cancelJob(store, "queued"); !errors.Is(err, ErrJobState) {
+		t.Errorf("cancelling twice got %v, want ErrJobState", err)
+	}
+	job, err = retryJob(store, "queued")
+	if err != nil || job.Status != JobQueued || job.Attempts != 0 || !job.FinishedAt.IsZero() || job.Error != "" {
+		t.Fatalf("retrying a cancelled job got %+v, %v", job, err)
+	}
+	if _, err := retryJob(store, "queued"); !errors.Is(err, ErrJobState) {
+		t.Errorf("retrying a queued job got %v, want ErrJobState", err)
+	}
+
+	// A running job is cancelled for its worker to stop, and cannot be retried before it has
+	if _, err := store.Claim(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	job, err = cancelJob(store, "queued")
+	if err != nil || job.Status != JobCancelled || !job.FinishedAt.IsZero() {
+		t.Fatalf("cancelling a running job got %+v, %v", job, err)
+	}
+	if _, err := retryJob(store, "queued"); !errors.Is(err, ErrJobState) {
+		t.Errorf("retrying a stopping job got %v, want ErrJobState", err)
+	}
+	if _, err := cancelJob(store, "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("cancelling an unknown job got %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestRunJobSchedulesRetries(t *testing.T) {
+	retry := RetryPolicy{MaxRetries: 1, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	s, _ := newTestServer(t, ServerConfig{Retry: retry, Base: SummaryConfig{VideoOnly: true}})
+	if err := s.store.Create(&Job{ID: "a", Status: JobQueued, InputKind: JobInputPath, Input: filepath.Join(t.TempDir(), "missing"), CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The run fails on the missing input and is queued again after its backoff
+	job, rj, err := s.claim()
+	if err != nil || job == nil {
+		t.Fatalf("claim: %v, %v", job, err)
+	}
+	s.runJob(quietContext(), job, rj)
+	stored, err := s.store.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != JobQueued || stored.Error == "" || stored.Attempts != 1 {
+		t.Fatalf("failed run left %+v, want it queued with its error", stored)
+	}
+	if wait := stored.NextAttemptAt.Sub(stored.FinishedAt); wait < retry.BaseDelay/2 || wait > retry.BaseDelay {
+		t.Errorf("retry in %v, want between %v and %v", wait, retry.BaseDelay/2, retry.BaseDelay)
+	}
+	if job, _, _ := s.claim(); job != nil {
+		t.Fatal("the retry was claimed before it was due")
+	}
+
+	// The last retry fails for good
+	job, err = s.store.Claim(stored.NextAttemptAt)
+	if err != nil || job == nil {
+		t.Fatalf("claim at the retry time: %v, %v", job, err)
+	}
+	s.runJob(quietContext(), job, &runningJob{progress: map[string]ProgressEvent{}, subscribers: map[chan jobEvent]struct{}{}})
+	if stored, _ := s.store.Get("a"); stored.Status != JobFailed || stored.Attempts != 2 || !stored.NextAttemptAt.IsZero() {
+		t.Errorf("last attempt left %+v, want it failed", stored)
+	}
+}
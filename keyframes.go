@@ -0,0 +1,186 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Video transcription modes selectable through SummaryConfig.VideoTranscription
+const (
+	// VideoTranscriptionUpload uploads every chunk MP4 through the Files API (default)
+	VideoTranscriptionUpload = "upload"
+	// VideoTranscriptionKeyframes sends deduplicated 1 fps frames inline as images, which is far
+	// cheaper for slide-heavy content.
+	VideoTranscriptionKeyframes = "keyframes"
+)
+
+const (
+	// defaultKeyframeBatchBytes keeps inline requests well under Gemini's 20 MB request limit
+	defaultKeyframeBatchBytes = 8 << 20
+	// keyframeHashDistance is the max number of differing average-hash bits for two frames to
+	// count as the same slide
+	keyframeHashDistance = 5
+)
+
+const keyframePromptTask = `## Task Description
+The images below are frames sampled from a video, each preceded by its timestamp. Provide a detailed raw transcription of the text displayed in them, in order. Mark where the content changes with the timestamp of the frame it first appears in.`
+
+// keyframe is a frame kept after deduplication
+type keyframe struct {
+	Path string
+	// Time is the frame's position in the source video
+	Time time.Duration
+	Data []byte
+}
+
+// averageHash computes a 64-bit average hash: the image shrunk to 8x8 grayscale, one bit per
+// cell that is brighter than the mean. Near-identical frames differ in only a few bits.
+func averageHash(img image.Image) uint64 {
+	b := img.Bounds()
+	var cells [64]float64
+	var counts [64]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * 8 / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * 8 / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			cells[cy*8+cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			counts[cy*8+cx]++
+		}
+	}
+	var mean float64
+	for i := range cells {
+		if counts[i] > 0 {
+			cells[i] /= float64(counts[i])
+		}
+		mean += cells[i]
+	}
+	mean /= 64
+
+	var hash uint64
+	for i, v := range cells {
+		if v > mean {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// selectKeyframes reads the 1 fps frames from extractFrames and drops each frame that looks like
+// the last kept one. offset is the chunk's position in the video.
+func selectKeyframes(framePaths []string, offset time.Duration) ([]keyframe, error) {
+	var frames []keyframe
+	var lastHash uint64
+	for i, path := range framePaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading frame %s: %w", path, err)
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding frame %s: %w", path, err)
+		}
+		hash := averageHash(img)
+		if len(frames) > 0 && bits.OnesCount64(hash^lastHash) <= keyframeHashDistance {
+			continue
+		}
+		lastHash = hash
+		// extractFrames samples at 1 fps, so frame i is i seconds into the chunk
+		frames = append(frames, keyframe{Path: path, Time: offset + time.Duration(i)*time.Second, Data: data})
+	}
+	return frames, nil
+}
+
+// batchKeyframes splits frames into batches whose image bytes stay within maxBytes.
+// A single frame larger than maxBytes gets a batch of its own.
+func batchKeyframes(frames []keyframe, maxBytes int) [][]keyframe {
+	var batches [][]keyframe
+	var current []keyframe
+	size := 0
+	for _, f := range frames {
+		if len(current) > 0 && size+len(f.Data) > maxBytes {
+			batches = append(batches, current)
+			current, size = nil, 0
+		}
+		current = append(current, f)
+		size += len(f.Data)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// transcribeVideoKeyframes transcribes a chunk from deduplicated frames sent inline. It falls
+// back to Tesseract on the same frames when the LLM fails.
+func transcribeVideoKeyframes(ctx context.Context, llm *llmCaller, model *stageModel, videoPath string, videoIndex int, chunkNum int, offset time.Duration, batchBytes int, stats *ChunkReport) (string, error) {
+	framePaths, err := extractFrames(ctx, videoPath, videoIndex, chunkNum)
+	if err != nil {
+		return "", fmt.Errorf("error extracting frames for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	if len(framePaths) > 0 {
+		defer os.RemoveAll(filepath.Dir(framePaths[0]))
+	}
+
+	transcript, err := transcribeKeyframesLLM(ctx, llm, model, framePaths, videoIndex, chunkNum, offset, batchBytes, "")
+	if errors.Is(err, context.Canceled) {
+		return "", err
+	}
+	if err != nil {
+		logFrom(ctx).Warn("LLM keyframe transcription failed, falling back to Tesseract", "error", err)
+		stats.addTesseractFrames(len(framePaths))
+		ocr, err := TranscribeVideoTesseractAPI(ctx, framePaths)
+		if err != nil {
+			return "", fmt.Errorf("error transcribing frames with Tesseract for video %d chunk %d: %w", videoIndex, chunkNum, err)
+		}
+		return ocr, nil
+	}
+	return transcript, nil
+}
+
+// transcribeKeyframesLLM deduplicates framePaths and sends the kept frames inline, one
+// GenerateContent call per batch of at most batchBytes. ocrGrounding, when set, is Tesseract
+// output passed along with every batch as a reference.
+func transcribeKeyframesLLM(ctx context.Context, llm *llmCaller, model *stageModel, framePaths []string, videoIndex int, chunkNum int, offset time.Duration, batchBytes int, ocrGrounding string) (string, error) {
+	frames, err := selectKeyframes(framePaths, offset)
+	if err != nil {
+		return "", err
+	}
+	if batchBytes <= 0 {
+		batchBytes = defaultKeyframeBatchBytes
+	}
+	batches := batchKeyframes(frames, batchBytes)
+	logFrom(ctx).Debug("Sending keyframes inline", "keyframes", len(frames), "frames", len(framePaths), "requests", len(batches))
+
+	var transcript strings.Builder
+	for _, batch := range batches {
+		parts := []genai.Part{genai.Text(keyframePromptTask)}
+		for _, f := range batch {
+			parts = append(parts, genai.Text("Frame at "+formatWhisperTimestamp(f.Time)+":"), genai.ImageData("jpeg", f.Data))
+		}
+		if ocrGrounding != "" {
+			parts = append(parts, ocrGroundingPart(ocrGrounding))
+		}
+		text, err := llm.sentLlmPrompt(ctx, model, parts, nil, videoIndex, llmStageVideoTranscription, chunkNum)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			return "", errors.New("LLM transcription is empty")
+		}
+		transcript.WriteString(text)
+		transcript.WriteString("\n")
+	}
+	logFrom(ctx).Debug("Video transcribed by LLM from keyframes")
+	return transcript.String(), nil
+}
@@ -0,0 +1,184 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Stages an LLM call is made for, as recorded in the run report
+const (
+	llmStageVideoTranscription = "video_transcription"
+	llmStageChunkSummary       = "chunk_summary"
+	llmStageSummary            = "summary"
+)
+
+// maxContinuations bounds how often a MAX_TOKENS response is continued
+const maxContinuations = 5
+
+const continuePrompt = `Your previous response was cut off. Continue exactly where it stopped, without repeating anything you already wrote and without any preamble.`
+
+// SafetyRatingRecord is one safety rating of a prompt or response
+type SafetyRatingRecord struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// LLMCallRecord describes one sentLlmPrompt call, including its continuations
+type LLMCallRecord struct {
+	Stage      string `json:"stage"`
+	VideoIndex int    `json:"video_index"`
+	// Model is the model that produced the response (or last failed)
+	Model    string `json:"model"`
+	FellBack bool   `json:"fell_back,omitempty"`
+	// ChunkNum is the chunk the call was made for, -1 for whole-video calls
+	ChunkNum int `json:"chunk_num"`
+	// Attempts counts every request sent, including retries and continuations
+	Attempts      int `json:"attempts"`
+	Continuations int `json:"continuations,omitempty"`
+	// FinishReason is the finish reason of the last response, e.g. FinishReasonStop or FinishReasonMaxTokens
+	FinishReason string `json:"finish_reason,omitempty"`
+	// BlockReason is set when the prompt itself was blocked
+	BlockReason    string               `json:"block_reason,omitempty"`
+	SafetyRatings  []SafetyRatingRecord `json:"safety_ratings,omitempty"`
+	PromptTokens   int32                `json:"prompt_tokens,omitempty"`
+	ResponseTokens int32                `json:"response_tokens,omitempty"`
+	Truncated      bool                 `json:"truncated,omitempty"`
+	Error          string               `json:"error,omitempty"`
+}
+
+func safetyRatingRecords(ratings []*genai.SafetyRating) []SafetyRatingRecord {
+	var records []SafetyRatingRecord
+	for _, r := range ratings {
+		if r == nil {
+			continue
+		}
+		records = append(records, SafetyRatingRecord{Category: r.Category.String(), Probability: r.Probability.String(), Blocked: r.Blocked})
+	}
+	return records
+}
+
+// noteCandidate records the finish reason and safety ratings of a response candidate
+func (r *LLMCallRecord) noteCandidate(c *genai.Candidate) {
+	r.FinishReason = c.FinishReason.String()
+	r.SafetyRatings = safetyRatingRecords(c.SafetyRatings)
+}
+
+// noteError records a failed call, including why it was blocked
+func (r *LLMCallRecord) noteError(err error) {
+	r.Error = err.Error()
+	var blocked *genai.BlockedError
+	if !errors.As(err, &blocked) {
+		return
+	}
+	if blocked.Candidate != nil {
+		r.noteCandidate(blocked.Candidate)
+	}
+	if blocked.PromptFeedback != nil {
+		r.BlockReason = blocked.PromptFeedback.BlockReason.String()
+		r.SafetyRatings = safetyRatingRecords(blocked.PromptFeedback.SafetyRatings)
+	}
+}
+
+// flaggedRatings lists the ratings above negligible, for log lines
+func (r *LLMCallRecord) flaggedRatings() string {
+	var flagged []string
+	for _, s := range r.SafetyRatings {
+		if s.Blocked || s.Probability != genai.HarmProbabilityNegligible.String() {
+			flagged = append(flagged, fmt.Sprintf("%s=%s", s.Category, s.Probability))
+		}
+	}
+	return strings.Join(flagged, ", ")
+}
+
+// collectResponse takes the text of the first candidate and records the finish reason, safety
+// ratings and token usage. The usage replaces the call's quota reservation, which is then zeroed.
+func (c *llmCaller) collectResponse(ctx context.Context, resp *genai.GenerateContentResponse, record *LLMCallRecord, reserved *int64) string {
+	if resp.UsageMetadata != nil {
+		record.PromptTokens += resp.UsageMetadata.PromptTokenCount
+		record.ResponseTokens += resp.UsageMetadata.CandidatesTokenCount
+		c.quota.Record(ctx, int64(resp.UsageMetadata.TotalTokenCount), *reserved)
+		*reserved = 0
+	}
+	if len(resp.Candidates) == 0 {
+		record.FinishReason = ""
+		return ""
+	}
+	candidate := resp.Candidates[0]
+	record.noteCandidate(candidate)
+
+	var text strings.Builder
+	if candidate.Content != nil {
+		for _, part := range candidate.Content.Parts {
+			if t, ok := part.(genai.Text); ok {
+				text.WriteString(string(t))
+			}
+		}
+	}
+	return text.String()
+}
+
+// continueResponse collects resp and, while the response stops at MAX_TOKENS, up to
+// maxContinuations continuations that next generates for the text so far. A failed continuation
+// keeps the text so far, marked truncated; only cancellation is returned as an error.
+func (c *llmCaller) continueResponse(ctx context.Context, resp *genai.GenerateContentResponse, record *LLMCallRecord, reserved *int64, next func(ctx context.Context, soFar string) (*genai.GenerateContentResponse, error)) (string, error) {
+	logger := logFrom(ctx)
+	text := c.collectResponse(ctx, resp, record, reserved)
+	for record.FinishReason == genai.FinishReasonMaxTokens.String() {
+		if record.Continuations >= maxContinuations {
+			logger.Warn("LLM response is still truncated after the maximum continuations", "continuations", record.Continuations)
+			record.Truncated = true
+			break
+		}
+		record.Continuations++
+		logger.Info("LLM response hit MAX_TOKENS, continuing generation", "continuation", record.Continuations, "max_continuations", maxContinuations)
+		resp, err := next(ctx, text)
+		if err != nil {
+			record.noteError(err)
+			if errors.Is(err, context.Canceled) {
+				return "", err
+			}
+			// Keep what was generated so far instead of losing the whole response
+			logger.Warn("Continuing the LLM response failed, keeping the truncated response", "error", err)
+			record.Truncated = true
+			break
+		}
+		text += c.collectResponse(ctx, resp, record, reserved)
+	}
+	return text, nil
+}
+
+// writeResponse writes a whole LLM response, continuations included, to file when there is one,
+// so the file holds exactly the text returned for the summary and the report
+func writeResponse(ctx context.Context, file *os.File, text string) {
+	if file == nil {
+		return
+	}
+	if _, err := io.WriteString(file, text); err != nil {
+		logFrom(ctx).Error("Error writing LLM response to file", "path", file.Name(), "error", err)
+	}
+}
+
+// takeCalls returns and forgets the calls recorded for videoIndex
+func (c *llmCaller) takeCalls(videoIndex int) []LLMCallRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := c.calls[videoIndex]
+	delete(c.calls, videoIndex)
+	return calls
+}
+
+func (c *llmCaller) recordCall(record LLMCallRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[int][]LLMCallRecord)
+	}
+	c.calls[record.VideoIndex] = append(c.calls[record.VideoIndex], record)
+}
@@ -0,0 +1,208 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// fakeGemini answers generateContent requests with the scripted JSON responses in turn and
+// keeps the request bodies
+type fakeGemini struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []string
+}
+
+func (f *fakeGemini) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, string(body))
+	if !strings.HasSuffix(r.URL.Path, ":generateContent") || len(f.responses) == 0 {
+		http.Error(w, `{"error": {"code": 404, "message": "unexpected request"}}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, f.responses[0])
+	f.responses = f.responses[1:]
+}
+
+// newFakeGeminiClient returns a client whose requests go to a fake server answering with responses
+func newFakeGeminiClient(t *testing.T, responses ...string) (*genai.Client, *fakeGemini) {
+	t.Helper()
+	fake := &fakeGemini{responses: responses}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey("test"), option.WithEndpoint(server.URL), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, fake
+}
+
+// geminiResponse encodes a response with one candidate made of parts
+func geminiResponse(t *testing.T, finishReason string, parts ...string) string {
+	t.Helper()
+	var content []map[string]string
+	for _, p := range parts {
+		content = append(content, map[string]string{"text": p})
+	}
+	data, err := json.Marshal(map[string]any{
+		"candidates": []map[string]any{{
+			"content":      map[string]any{"role": "model", "parts": content},
+			"finishReason": finishReason,
+		}},
+		"usageMetadata": map[string]int{"promptTokenCount": 10, "candidatesTokenCount": 5, "totalTokenCount": 15},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// textResponse builds a response with one candidate made of parts
+func textResponse(finishReason genai.FinishReason, parts ...string) *genai.GenerateContentResponse {
+	content := &genai.Content{Role: "model"}
+	for _, p := range parts {
+		content.Parts = append(content.Parts, genai.Text(p))
+	}
+	return &genai.GenerateContentResponse{
+		Candidates:    []*genai.Candidate{{Content: content, FinishReason: finishReason}},
+		UsageMetadata: &genai.UsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 5, TotalTokenCount: 15},
+	}
+}
+
+func quietContext() context.Context {
+	return withLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestSentLlmPromptWritesReturnedText(t *testing.T) {
+	client, fake := newFakeGeminiClient(t, geminiResponse(t, "STOP", "First part.", "\nSecond part."))
+	llm, err := newLLMCaller(&SummaryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "summary.txt")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	models := newStageModel(client, "gemini-test", ModelRoute{}, GenerationConfig{})
+	text, err := llm.sentLlmPrompt(quietContext(), models, []genai.Part{genai.Text("Summarize")}, file, 1, llmStageSummary, -1)
+	if err != nil {
+		t.Fatalf("sentLlmPrompt: %v", err)
+	}
+	if want := "First part.\nSecond part."; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	if written, _ := os.ReadFile(path); string(written) != text {
+		t.Errorf("file holds %q, want the returned %q", written, text)
+	}
+	if len(fake.requests) != 1 {
+		t.Errorf("sent %d requests, want 1", len(fake.requests))
+	}
+}
+
+func TestContinueResponse(t *testing.T) {
+	first := textResponse(genai.FinishReasonMaxTokens, "The talk covers ", "two top")
+	tests := []struct {
+		name          string
+		continuations []*genai.GenerateContentResponse
+		errs          []error
+		want          string
+		wantTruncated bool
+	}{
+		{"finished", nil, nil, "The talk covers two top", false},
+		{
+			"multi-part continuations",
+			[]*genai.GenerateContentResponse{
+				textResponse(genai.FinishReasonMaxTokens, "ics: cach", "ing"),
+				textResponse(genai.FinishReasonStop, " and ", "retries."),
+			},
+			nil,
+			"The talk covers two topics: caching and retries.",
+			false,
+		},
+		{
+			"failed continuation keeps the text so far",
+			[]*genai.GenerateContentResponse{textResponse(genai.FinishReasonMaxTokens, "ics")},
+			[]error{nil, errors.New("backend unavailable")},
+			"The talk covers two topics",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := first
+			if tt.continuations == nil {
+				resp = textResponse(genai.FinishReasonStop, "The talk covers ", "two top")
+			}
+			llm := &llmCaller{}
+			record := LLMCallRecord{}
+			var reserved int64
+			var soFars []string
+			text, err := llm.continueResponse(quietContext(), resp, &record, &reserved, func(ctx context.Context, soFar string) (*genai.GenerateContentResponse, error) {
+				i := len(soFars)
+				soFars = append(soFars, soFar)
+				if i < len(tt.errs) && tt.errs[i] != nil {
+					return nil, tt.errs[i]
+				}
+				return tt.continuations[i], nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.want {
+				t.Errorf("got %q, want %q", text, tt.want)
+			}
+			if record.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", record.Truncated, tt.wantTruncated)
+			}
+			// Each continuation is asked for with everything generated before it
+			for i, soFar := range soFars {
+				if !strings.HasPrefix(tt.want, soFar) || (i > 0 && len(soFar) <= len(soFars[i-1])) {
+					t.Errorf("continuation %d was sent %q", i+1, soFar)
+				}
+			}
+
+			// The file gets the same bytes as the caller
+			path := filepath.Join(t.TempDir(), "out.txt")
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeResponse(quietContext(), file, text)
+			file.Close()
+			if written, _ := os.ReadFile(path); string(written) != text {
+				t.Errorf("file holds %q, want %q", written, text)
+			}
+		})
+	}
+}
+
+func TestContinueResponseCancelled(t *testing.T) {
+	llm := &llmCaller{}
+	var reserved int64
+	_, err := llm.continueResponse(quietContext(), textResponse(genai.FinishReasonMaxTokens, "partial"), &LLMCallRecord{}, &reserved, func(ctx context.Context, soFar string) (*genai.GenerateContentResponse, error) {
+		return nil, &LLMError{Kind: LLMErrorCancelled, Err: context.Canceled}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,218 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LLMErrorKind classifies why an LLM call failed
+type LLMErrorKind int
+
+const (
+	// LLMErrorTransient covers network errors, 5xx and server-side timeouts; retried with backoff.
+	LLMErrorTransient LLMErrorKind = iota
+	// LLMErrorRateLimited is a 429 / RESOURCE_EXHAUSTED; retried, honouring the server's retry delay.
+	LLMErrorRateLimited
+	// LLMErrorAuth is a missing or invalid API key; not retried, and fails the whole run.
+	LLMErrorAuth
+	// LLMErrorInvalidArgument is a malformed request; not retried.
+	LLMErrorInvalidArgument
+	// LLMErrorSafetyBlocked means the prompt or response was blocked by safety filters; not retried.
+	LLMErrorSafetyBlocked
+	// LLMErrorContextTooLong means the prompt exceeds the model's input token limit; not retried.
+	LLMErrorContextTooLong
+	// LLMErrorCancelled means the run's context was cancelled.
+	LLMErrorCancelled
+	// LLMErrorBudgetExceeded means the call would exceed QuotaConfig.DailyTokenBudget; not sent.
+	LLMErrorBudgetExceeded
+	// LLMErrorModelUnavailable means the model does not exist or is not served to this key; not retried.
+	LLMErrorModelUnavailable
+	// LLMErrorPermissionDenied is a 403 for one resource, e.g. an uploaded file of another project
+	// or a model not enabled for the key; not retried, and only fails the call.
+	LLMErrorPermissionDenied
+)
+
+var llmErrorKindNames = map[LLMErrorKind]string{
+	LLMErrorTransient:        "transient",
+	LLMErrorRateLimited:      "rate limited",
+	LLMErrorAuth:             "authentication",
+	LLMErrorInvalidArgument:  "invalid argument",
+	LLMErrorSafetyBlocked:    "safety blocked",
+	LLMErrorContextTooLong:   "context too long",
+	LLMErrorCancelled:        "cancelled",
+	LLMErrorModelUnavailable: "model unavailable",
+	LLMErrorBudgetExceeded:   "daily budget exceeded",
+	LLMErrorPermissionDenied: "permission denied",
+}
+
+func (k LLMErrorKind) String() string {
+	if name, ok := llmErrorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("LLMErrorKind(%d)", int(k))
+}
+
+// Sentinel errors matched by errors.Is against an *LLMError of the corresponding kind
+var (
+	ErrLLMRateLimited      = errors.New("llm rate limited")
+	ErrLLMAuth             = errors.New("llm authentication failed")
+	ErrLLMInvalidArgument  = errors.New("llm invalid argument")
+	ErrLLMSafetyBlocked    = errors.New("llm safety blocked")
+	ErrLLMContextTooLong   = errors.New("llm context too long")
+	ErrLLMModelUnavailable = errors.New("llm model unavailable")
+	ErrLLMPermissionDenied = errors.New("llm permission denied")
+)
+
+var llmErrorKindSentinels = map[LLMErrorKind]error{
+	LLMErrorRateLimited:      ErrLLMRateLimited,
+	LLMErrorAuth:             ErrLLMAuth,
+	LLMErrorInvalidArgument:  ErrLLMInvalidArgument,
+	LLMErrorSafetyBlocked:    ErrLLMSafetyBlocked,
+	LLMErrorContextTooLong:   ErrLLMContextTooLong,
+	LLMErrorModelUnavailable: ErrLLMModelUnavailable,
+	LLMErrorBudgetExceeded:   ErrDailyBudgetExceeded,
+	LLMErrorPermissionDenied: ErrLLMPermissionDenied,
+}
+
+// LLMError is returned by LLM calls that failed permanently or ran out of retries
+type LLMError struct {
+	Kind LLMErrorKind
+	// Attempts is how many times the call was sent
+	Attempts int
+	// RetryAfter is the server's requested delay for rate-limited calls, zero if none was given
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	return fmt.Sprintf("llm call failed (%s) after %d attempt(s): %v", e.Kind, e.Attempts, e.Err)
+}
+
+func (e *LLMError) Unwrap() error { return e.Err }
+
+func (e *LLMError) Is(target error) bool {
+	return target != nil && llmErrorKindSentinels[e.Kind] == target
+}
+
+// Retryable reports whether sending the same request again may succeed
+func (e *LLMError) Retryable() bool {
+	return e.Kind == LLMErrorTransient || e.Kind == LLMErrorRateLimited
+}
+
+// classifyLLMError wraps err from a GenerateContent/CountTokens/upload call in an *LLMError
+func classifyLLMError(ctx context.Context, err error) *LLMError {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr
+	}
+	classified := &LLMError{Kind: LLMErrorTransient, Err: err}
+
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		classified.Kind = LLMErrorCancelled
+		return classified
+	}
+
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) {
+		classified.Kind = LLMErrorSafetyBlocked
+		return classified
+	}
+
+	code := codes.Unknown
+	httpCode := 0
+	message := err.Error()
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) {
+		httpCode = apiErr.HTTPCode()
+		if st := apiErr.GRPCStatus(); st != nil {
+			code = st.Code()
+			message = st.Message()
+		}
+		if retryInfo := apiErr.Details().RetryInfo; retryInfo != nil {
+			classified.RetryAfter = retryInfo.GetRetryDelay().AsDuration()
+		}
+	} else if st, ok := status.FromError(err); ok {
+		code = st.Code()
+		message = st.Message()
+	}
+
+	lowerMessage := strings.ToLower(message)
+	switch {
+	case code == codes.ResourceExhausted || httpCode == http.StatusTooManyRequests:
+		classified.Kind = LLMErrorRateLimited
+	case code == codes.Unauthenticated || httpCode == http.StatusUnauthorized || strings.Contains(lowerMessage, "api key not valid"):
+		classified.Kind = LLMErrorAuth
+	case code == codes.PermissionDenied || httpCode == http.StatusForbidden:
+		classified.Kind = LLMErrorPermissionDenied
+	case code == codes.NotFound || httpCode == http.StatusNotFound:
+		classified.Kind = LLMErrorModelUnavailable
+	case code == codes.InvalidArgument || httpCode == http.StatusBadRequest:
+		if strings.Contains(lowerMessage, "token") && (strings.Contains(lowerMessage, "exceeds") || strings.Contains(lowerMessage, "too long") || strings.Contains(lowerMessage, "maximum")) {
+			classified.Kind = LLMErrorContextTooLong
+		} else {
+			classified.Kind = LLMErrorInvalidArgument
+		}
+	}
+	return classified
+}
+
+// canFallBack reports whether another model might serve the request: the primary is missing,
+// not enabled, out of quota, or still failing after retries.
+func (e *LLMError) canFallBack() bool {
+	switch e.Kind {
+	case LLMErrorModelUnavailable, LLMErrorPermissionDenied, LLMErrorRateLimited, LLMErrorTransient:
+		return true
+	}
+	return false
+}
+
+// RetryPolicy controls how failed LLM calls are retried: exponential backoff with full jitter,
+// never waiting less than a rate-limit retry delay requested by the server.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy is used when SummaryConfig.RetryPolicy is left zero
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  2 * time.Second,
+	MaxDelay:   2 * time.Minute,
+}
+
+// delay returns how long to wait before retry number attempt (0-based)
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	wait := time.Duration(rand.Int64N(int64(p.backoff(attempt)) + 1))
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	return wait
+}
+
+// equalJitterDelay is delay with equal instead of full jitter: at least half the backoff, so a
+// retry is never scheduled right away. Used for failed jobs, which have no server to spread out.
+func (p RetryPolicy) equalJitterDelay(attempt int) time.Duration {
+	backoff := p.backoff(attempt)
+	half := backoff / 2
+	return half + time.Duration(rand.Int64N(int64(backoff-half)+1))
+}
+
+// backoff is the exponential backoff before retry number attempt, capped at MaxDelay
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.BaseDelay << attempt
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	return backoff
+}
@@ -0,0 +1,82 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpAPIError builds the *apierror.APIError a REST call returns for code
+func httpAPIError(t *testing.T, code int, message string) error {
+	t.Helper()
+	apiErr, ok := apierror.FromError(&googleapi.Error{Code: code, Message: message})
+	if !ok {
+		t.Fatalf("no APIError for HTTP %d", code)
+	}
+	return apiErr
+}
+
+func TestClassifyLLMError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		err       error
+		want      LLMErrorKind
+		retryable bool
+		sentinel  error
+	}{
+		{"grpc unauthenticated", nil, status.Error(codes.Unauthenticated, "no key"), LLMErrorAuth, false, ErrLLMAuth},
+		{"http 401", nil, httpAPIError(t, http.StatusUnauthorized, "unauthorized"), LLMErrorAuth, false, ErrLLMAuth},
+		{"invalid key message", nil, errors.New("googleapi: Error 400: API key not valid. Please pass a valid API key."), LLMErrorAuth, false, ErrLLMAuth},
+		{"grpc permission denied", nil, status.Error(codes.PermissionDenied, "file belongs to another project"), LLMErrorPermissionDenied, false, ErrLLMPermissionDenied},
+		{"http 403", nil, httpAPIError(t, http.StatusForbidden, "model not enabled"), LLMErrorPermissionDenied, false, ErrLLMPermissionDenied},
+		{"grpc resource exhausted", nil, status.Error(codes.ResourceExhausted, "quota"), LLMErrorRateLimited, true, ErrLLMRateLimited},
+		{"http 429", nil, httpAPIError(t, http.StatusTooManyRequests, "slow down"), LLMErrorRateLimited, true, ErrLLMRateLimited},
+		{"http 404", nil, httpAPIError(t, http.StatusNotFound, "models/gemini-9 is not found"), LLMErrorModelUnavailable, false, ErrLLMModelUnavailable},
+		{"context too long", nil, status.Error(codes.InvalidArgument, "The input token count exceeds the maximum number of tokens allowed"), LLMErrorContextTooLong, false, ErrLLMContextTooLong},
+		{"invalid argument", nil, httpAPIError(t, http.StatusBadRequest, "bad mime type"), LLMErrorInvalidArgument, false, ErrLLMInvalidArgument},
+		{"http 503", nil, httpAPIError(t, http.StatusServiceUnavailable, "overloaded"), LLMErrorTransient, true, nil},
+		{"network error", nil, errors.New("connection reset by peer"), LLMErrorTransient, true, nil},
+		{"safety block", nil, &genai.BlockedError{}, LLMErrorSafetyBlocked, false, ErrLLMSafetyBlocked},
+		{"cancelled context", cancelled, errors.New("request aborted"), LLMErrorCancelled, false, nil},
+		{"already classified", nil, &LLMError{Kind: LLMErrorBudgetExceeded, Err: ErrDailyBudgetExceeded}, LLMErrorBudgetExceeded, false, ErrDailyBudgetExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			got := classifyLLMError(ctx, tt.err)
+			if got.Kind != tt.want {
+				t.Fatalf("got kind %v, want %v", got.Kind, tt.want)
+			}
+			if got.Retryable() != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", got.Retryable(), tt.retryable)
+			}
+			if tt.sentinel != nil && !errors.Is(got, tt.sentinel) {
+				t.Errorf("does not match %v", tt.sentinel)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Error("does not wrap the original error")
+			}
+		})
+	}
+}
+
+func TestPermissionDeniedIsNotBatchFatal(t *testing.T) {
+	err := classifyLLMError(context.Background(), status.Error(codes.PermissionDenied, "denied"))
+	if errors.Is(err, ErrLLMAuth) {
+		t.Error("a 403 matches ErrLLMAuth, which aborts the batch")
+	}
+}
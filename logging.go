@@ -0,0 +1,121 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Attribute keys used on every log record about a video, chunk or LLM stage
+const (
+	logKeyVideo      = "video"
+	logKeyVideoIndex = "video_index"
+	logKeyChunk      = "chunk"
+	logKeyStage      = "stage"
+)
+
+// LogOptions configures the logger built by NewLogger
+type LogOptions struct {
+	// Level is the minimum level written to the console; slog.LevelInfo by default,
+	// slog.LevelWarn for --quiet and slog.LevelDebug for --verbose.
+	Level slog.Level
+	// JSON writes JSON records instead of logfmt-style text, for the console and the per-video log files
+	JSON bool
+}
+
+// NewLogger returns a logger writing to w according to opts
+func NewLogger(w io.Writer, opts LogOptions) *slog.Logger {
+	return slog.New(newLogHandler(w, opts.JSON, opts.Level))
+}
+
+func newLogHandler(w io.Writer, json bool, level slog.Level) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	if json {
+		return slog.NewJSONHandler(w, handlerOpts)
+	}
+	return slog.NewTextHandler(w, handlerOpts)
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context whose log records go to logger
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// withLogAttrs returns a context whose logger adds args (key-value pairs) to every record
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+	return withLogger(ctx, logFrom(ctx).With(args...))
+}
+
+// logFrom returns the context's logger, or slog.Default()
+func logFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// videoLogPath returns the log file written next to a video's outputs
+func videoLogPath(videoPath string) string {
+	baseName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	return filepath.Join(filepath.Dir(videoPath), baseName+".log")
+}
+
+// openVideoLog tees the context's logger into <base>.log next to the video. The file gets every
+// record at Info and above (Debug too when the console is verbose), whatever the console level.
+// The returned function closes the file.
+func openVideoLog(ctx context.Context, videoPath string, json bool) (context.Context, func(), error) {
+	file, err := os.Create(videoLogPath(videoPath))
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	level := slog.LevelInfo
+	if logFrom(ctx).Enabled(ctx, slog.LevelDebug) {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(teeHandler{logFrom(ctx).Handler(), newLogHandler(file, json, level)})
+	return withLogger(ctx, logger), func() { file.Close() }, nil
+}
+
+// teeHandler sends each record to every handler that accepts its level
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, h := range t {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -3,21 +3,28 @@ package videoSummaryGo
 import (
 	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
 	"image/jpeg"
 	"io"
 	"io/fs"
 	"log"
+	"log/slog"
+	"math"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/generative-ai-go/genai"
@@ -32,9 +39,121 @@ type ChunkData struct {
 	Err        error
 	VideoIndex int
 	BaseName   string
+	// Offset is where the chunk starts in the source video
+	Offset time.Duration
+	// Source is the video the chunk was cut from
+	Source string
 }
 
-func YoutubeDownloader(url string, customDestDir string) (string, error) {
+// defaultSilenceThresholdDB is the max_volume (as reported by ffmpeg volumedetect) at or below
+// which an audio chunk is considered silent. Digital silence reports around -91 dB.
+const defaultSilenceThresholdDB = -60.0
+
+// SummaryConfig holds every setting for a VideoSummaryWithConfig run
+type SummaryConfig struct {
+	LLM              string
+	APIKey           string
+	ChunkDuration    int
+	WhisperCLIPath   string
+	WhisperModelPath string
+	WhisperThreads   int
+	WhisperLanguage  string
+	InputPath        string
+	InputFromUser    string
+
+	// When InputPath is a folder, Include and Exclude are glob patterns (filepath.Match syntax)
+	// matched against each file's name and its path relative to the folder; an empty Include
+	// selects every video. Excluded subfolders are not searched. MaxDepth limits the search to
+	// that many levels, 1 being the folder itself; 0 is unlimited.
+	Include  []string
+	Exclude  []string
+	MaxDepth int
+	// Force summarizes every video of a folder, including those an earlier run already
+	// summarized; see alreadySummarized
+	Force bool
+
+	// VideoOnly skips audio extraction and whisper entirely and summarizes only the visual transcript.
+	VideoOnly bool
+	// VideoTranscription selects how the LLM sees each chunk: VideoTranscriptionUpload (default)
+	// or VideoTranscriptionKeyframes.
+	VideoTranscription string
+	// VideoOCR selects how Tesseract is used: VideoOCRFallback (default) or VideoOCRHybrid.
+	VideoOCR string
+	// KeyframeBatchBytes caps the inline image bytes per request in keyframes mode;
+	// defaultKeyframeBatchBytes when zero.
+	KeyframeBatchBytes int
+	// SilenceThresholdDB overrides defaultSilenceThresholdDB when set; 0 dB is a valid threshold.
+	SilenceThresholdDB *float64
+
+	// WhisperLanguage may be AutoDetectLanguage to detect the spoken language per chunk.
+	// WhisperTranslate makes whisper translate the audio transcript to English.
+	WhisperTranslate bool
+	// SummaryLanguage is the language the final summary is written in (defaultSummaryLanguage when empty),
+	// independent of the language spoken in the recording.
+	SummaryLanguage string
+
+	// Diarization labels speaker turns in the audio transcript: DiarizationTinydiarize or DiarizationStereo.
+	Diarization string
+	// Diarizer, when set, labels speakers after transcription with any backend.
+	Diarizer Diarizer
+	// SpeakerNamesPath is an optional JSON file mapping speaker labels to real names.
+	SpeakerNamesPath string
+	speakerNames     map[string]string
+
+	// RetryPolicy controls retries of failed LLM calls; zero means DefaultRetryPolicy.
+	RetryPolicy RetryPolicy
+	// Quota rate-limits Gemini requests and tokens and sets a daily token budget.
+	Quota QuotaConfig
+	// QuotaLimiter, when set, is used instead of building one from Quota, so several runs
+	// against the same API key can share one limiter.
+	QuotaLimiter *QuotaLimiter
+
+	// Models routes each task to its own model, with an optional fallback; unset tasks use LLM.
+	Models ModelRoutes
+	// Prices estimates the cost of LLM calls in the run report; DefaultPriceTable when nil.
+	Prices PriceTable
+	// Generation sets temperature, output limit, system instruction and safety settings per task.
+	Generation GenerationConfigs
+	// ChunkSummaries summarizes every chunk on its own first and builds the final summary from
+	// those, so long recordings don't overflow or truncate a single summary call.
+	ChunkSummaries bool
+
+	// WhisperBackend selects how audio is transcribed: WhisperBackendCLI (default), WhisperBackendBindings
+	// or WhisperBackendServer.
+	WhisperBackend string
+	// WhisperServerURL is the full endpoint for WhisperBackendServer, e.g. http://host:8080/inference
+	// (whisper.cpp server) or https://host/v1/audio/transcriptions (OpenAI-compatible).
+	WhisperServerURL    string
+	WhisperServerAPIKey string
+	// WhisperServerModel is sent as the "model" form field; OpenAI-compatible servers require it.
+	WhisperServerModel string
+	// AudioTranscriber, when set, is used instead of building one from WhisperBackend.
+	// The caller keeps ownership and must Close it.
+	AudioTranscriber AudioTranscriber
+
+	// Logger receives the run's log records; slog.Default() when nil. Each video's records are
+	// also written to <base>.log next to its outputs.
+	Logger *slog.Logger
+	// LogJSON writes the per-video log files as JSON instead of text
+	LogJSON bool
+	// Observer sees chunks, transcripts, summaries and errors as they happen; see SummarizeVideos
+	Observer Observer
+	// Progress receives chunks done per stage of every video, with ETAs; see NewTerminalProgress,
+	// NewPlainProgress and ProgressFunc.
+	Progress ProgressReporter
+	// Notifiers are told about every video when it is finished; see NewWebhookNotifier and
+	// NewEmailNotifier
+	Notifiers []Notifier
+}
+
+func (cfg SummaryConfig) silenceThreshold() float64 {
+	if cfg.SilenceThresholdDB != nil {
+		return *cfg.SilenceThresholdDB
+	}
+	return defaultSilenceThresholdDB
+}
+
+func YoutubeDownloader(ctx context.Context, url string, customDestDir string) (string, error) {
 	// Validate dependencies and URL
 	ytDlpPath, err := exec.LookPath("yt-dlp")
 	if err != nil {
@@ -59,7 +178,7 @@ func YoutubeDownloader(url string, customDestDir string) (string, error) {
 
 	// Download video
 	outputTemplate := filepath.Join(tempDir, "%(title)s-%(id)s.%(ext)s")
-	stdout, stderr, err := executeYTDLP(ytDlpPath, url, outputTemplate)
+	stdout, stderr, err := executeYTDLP(ctx, ytDlpPath, url, outputTemplate)
 	if err != nil {
 		return "", fmt.Errorf("download failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
 	}
@@ -97,7 +216,7 @@ func getDestinationDir(customDir string) string {
 		cwd, err := os.Getwd()
 		if err != nil {
 			// Fallback: Use a relative path if Getwd fails, though this is unlikely
-			log.Printf("Warning: Failed to get current directory: %v. Using relative path 'Videos'.", err)
+			slog.Warn("Failed to get current directory, using relative path Videos", "error", err)
 			return "Videos"
 		}
 		destDir = filepath.Join(cwd, "Videos")
@@ -106,17 +225,17 @@ func getDestinationDir(customDir string) string {
 	// Ensure the path is absolute
 	absDestDir, err := filepath.Abs(destDir)
 	if err != nil {
-		log.Printf("Warning: Failed to convert destination directory '%s' to absolute path: %v. Using original.", destDir, err)
+		slog.Warn("Failed to convert destination directory to absolute path, using original", "dir", destDir, "error", err)
 		return destDir // Return original if Abs fails
 	}
 
-	log.Printf("Resolved Destination Directory (Absolute): %s\n", absDestDir)
+	slog.Debug("Resolved destination directory", "dir", absDestDir)
 	return absDestDir
 }
 
-func executeYTDLP(ytDlpPath, url, outputTemplate string) (string, string, error) {
+func executeYTDLP(ctx context.Context, ytDlpPath, url, outputTemplate string) (string, string, error) {
 	var stdout, stderr strings.Builder
-	cmd := exec.Command(ytDlpPath,
+	cmd := exec.CommandContext(ctx, ytDlpPath,
 		"-o", outputTemplate,
 		"--merge-output-format", "mp4",
 		"--no-mtime",
@@ -148,14 +267,14 @@ func moveToDestination(tempFilePath, destDir string) (string, error) {
 		return "", fmt.Errorf("failed to get absolute path for final destination '%s': %w", destPath, err)
 	}
 
-	fmt.Printf("Attempting to move temp file '%s' to '%s'\n", tempFilePath, absDestPath)
+	slog.Debug("Moving downloaded file", "from", tempFilePath, "to", absDestPath)
 
 	// Remove existing file if present
 	if _, err := os.Stat(absDestPath); err == nil {
-		log.Printf("Removing existing file at destination: %s\n", absDestPath)
+		slog.Info("Removing existing file at destination", "path", absDestPath)
 		if err := os.Remove(absDestPath); err != nil {
 			// Log warning but proceed, copy might overwrite
-			log.Printf("Warning: Failed to remove existing file at '%s': %v", absDestPath, err)
+			slog.Warn("Failed to remove existing file", "path", absDestPath, "error", err)
 		}
 	}
 
@@ -164,7 +283,7 @@ func moveToDestination(tempFilePath, destDir string) (string, error) {
 		return "", fmt.Errorf("failed to copy file from '%s' to '%s': %w", tempFilePath, absDestPath, err)
 	}
 
-	log.Printf("Successfully copied file to: %s\n", absDestPath)
+	slog.Debug("Copied downloaded file", "path", absDestPath)
 	return absDestPath, nil // Return the final absolute path
 }
 
@@ -216,17 +335,17 @@ func extractFilenameFromOutput(output string, destinationDir string) (string, er
 		// Or sometimes it's absolute. Best bet: join with tempDir and check.
 		fullPath := filepath.Join(destinationDir, filepath.Base(potentialPath)) // Use Base to be safe
 		if _, err := os.Stat(fullPath); err == nil {
-			log.Printf("Extracted final filename from merge log: %s", fullPath)
+			slog.Debug("Extracted final filename from merge log", "path", fullPath)
 			return fullPath, nil
 		}
 		// If not found when joined, maybe the log path was already absolute? Check that.
 		if filepath.IsAbs(potentialPath) {
 			if _, err := os.Stat(potentialPath); err == nil {
-				log.Printf("Extracted final filename (absolute) from merge log: %s", potentialPath)
+				slog.Debug("Extracted final filename (absolute) from merge log", "path", potentialPath)
 				return potentialPath, nil
 			}
 		}
-		log.Printf("Warning: Merge pattern matched '%s', but couldn't verify path '%s' or '%s'.", potentialPath, fullPath, potentialPath)
+		slog.Warn("Merge pattern matched, but could not verify the path", "matched", potentialPath, "joined", fullPath)
 		// Fall through to Destination pattern
 	}
 
@@ -235,13 +354,13 @@ func extractFilenameFromOutput(output string, destinationDir string) (string, er
 	if match := regexp.MustCompile(destPattern).FindStringSubmatch(output); len(match) > 1 {
 		filePath := match[1]
 		// This path *should* be the one specified by -o, hence within destinationDir
-		log.Printf("Extracted filename from destination log: %s", filePath)
+		slog.Debug("Extracted filename from destination log", "path", filePath)
 		if _, err := os.Stat(filePath); err == nil {
 			// Return this only as a fallback, maybe log a warning
-			log.Printf("Warning: Using filename from 'Destination:' log, might be intermediate: %s", filePath)
+			slog.Warn("Using filename from Destination: log, might be intermediate", "path", filePath)
 			return filePath, nil
 		}
-		log.Printf("Warning: Destination pattern matched '%s', but os.Stat failed.", filePath)
+		slog.Warn("Destination pattern matched, but the file does not exist", "path", filePath)
 	}
 
 	return "", fmt.Errorf("filename not found reliably in yt-dlp output")
@@ -249,30 +368,30 @@ func extractFilenameFromOutput(output string, destinationDir string) (string, er
 
 // Adjust findDownloadedFile to be slightly more robust with fallbacks
 func findDownloadedFile(stdout, tempDir string) (string, error) {
-	log.Printf("Attempting to extract filename from yt-dlp stdout...")
+	slog.Debug("Extracting filename from yt-dlp stdout")
 	if path, err := extractFilenameFromOutput(stdout, tempDir); err == nil {
-		log.Printf("Found path via regex: %s", path)
+		slog.Debug("Found path via regex", "path", path)
 		// Double-check existence here before returning
 		if _, statErr := os.Stat(path); statErr == nil {
 			// Check if it's the FINAL expected format (mp4)
 			if strings.HasSuffix(strings.ToLower(path), ".mp4") && !regexp.MustCompile(`\.f\d+\.mp4$`).MatchString(path) {
-				log.Printf("Regex found final MP4 path: %s", path)
+				slog.Debug("Regex found final MP4 path", "path", path)
 				return path, nil
 			} else {
-				log.Printf("Warning: Regex found path '%s', but it looks like an intermediate format or non-MP4. Will try Glob.", path)
+				slog.Warn("Regex found an intermediate or non-MP4 path, trying glob", "path", path)
 				// Continue to Glob fallback
 			}
 
 		} else {
-			log.Printf("Warning: Regex found path '%s', but os.Stat failed: %v. Falling back to glob.", path, statErr)
+			slog.Warn("Regex found a path that does not exist, falling back to glob", "path", path, "error", statErr)
 			// Continue to Glob fallback
 		}
 	} else {
-		log.Printf("Failed to extract filename via regex: %v. Falling back to glob.", err)
+		slog.Debug("Failed to extract filename via regex, falling back to glob", "error", err)
 	}
 
 	// Fallback: find the final MP4 file specifically
-	log.Printf("Falling back to searching for *.mp4 in %s", tempDir)
+	slog.Debug("Searching for *.mp4", "dir", tempDir)
 	globPattern := filepath.Join(tempDir, "*.mp4")
 	files, err := filepath.Glob(globPattern)
 	if err != nil {
@@ -289,15 +408,15 @@ func findDownloadedFile(stdout, tempDir string) (string, error) {
 	}
 
 	if len(finalFiles) == 1 {
-		log.Printf("Found final MP4 file via glob: %s", finalFiles[0])
+		slog.Debug("Found final MP4 file via glob", "path", finalFiles[0])
 		return finalFiles[0], nil
 	} else if len(finalFiles) > 1 {
-		log.Printf("Warning: Found multiple potential final MP4 files via glob: %v. Returning the first one: %s", finalFiles, finalFiles[0])
+		slog.Warn("Found multiple potential final MP4 files via glob, using the first one", "files", finalFiles, "path", finalFiles[0])
 		return finalFiles[0], nil // Or return error? Choosing first is pragmatic.
 	} else {
 		// If no non-intermediate MP4, maybe merge failed? Look for *any* MP4 as last resort.
 		if len(files) > 0 {
-			log.Printf("Warning: No clear final MP4 found via glob. Returning first MP4 found (might be intermediate): %s", files[0])
+			slog.Warn("No clear final MP4 found via glob, using the first MP4 (might be intermediate)", "path", files[0])
 			return files[0], nil
 		}
 		// Last resort: Check other common extensions
@@ -305,7 +424,7 @@ func findDownloadedFile(stdout, tempDir string) (string, error) {
 		for _, pattern := range otherVideoPatterns {
 			otherFiles, _ := filepath.Glob(filepath.Join(tempDir, pattern))
 			if len(otherFiles) > 0 {
-				log.Printf("Found fallback video file (non-mp4): %s", otherFiles[0])
+				slog.Info("Found fallback video file (non-mp4)", "path", otherFiles[0])
 				return otherFiles[0], nil
 			}
 		}
@@ -314,91 +433,205 @@ func findDownloadedFile(stdout, tempDir string) (string, error) {
 }
 
 // SetLlmApi function
-func SetLlmApi(llm string, apiKey string) (*genai.Client, *genai.GenerativeModel, context.Context, error) {
-	ctx := context.Background()
+func SetLlmApi(ctx context.Context, llm string, apiKey string) (*genai.Client, *genai.GenerativeModel, error) {
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("error creating genai client: %w", err)
+		return nil, nil, fmt.Errorf("error creating genai client: %w", err)
 	}
 	model := client.GenerativeModel(llm)
-	fmt.Println("LLM API setup complete.")
-	return client, model, ctx, nil
+	slog.Debug("LLM API setup complete")
+	return client, model, nil
 }
 
-const (
-	maxRetries = 5                // Maximum number of retry attempts for LLM calls
-	retryDelay = 30 * time.Second // Delay between retry attempts
-)
+// llmCaller sends prompts to Gemini on behalf of one run
+type llmCaller struct {
+	retry RetryPolicy
+	quota *QuotaLimiter
+
+	mu    sync.Mutex
+	calls map[int][]LLMCallRecord // by video index, for the run report
+}
+
+func newLLMCaller(cfg *SummaryConfig) (*llmCaller, error) {
+	retry := cfg.RetryPolicy
+	if retry == (RetryPolicy{}) {
+		retry = DefaultRetryPolicy
+	}
+	quota := cfg.QuotaLimiter
+	if quota == nil {
+		var err error
+		if quota, err = NewQuotaLimiter(cfg.Quota); err != nil {
+			return nil, err
+		}
+	}
+	return &llmCaller{retry: retry, quota: quota}, nil
+}
 
 // sentLlmPrompt function
-func sentLlmPrompt(model *genai.GenerativeModel, prompt []genai.Part, ctx context.Context, file *os.File, videoIndex int) string {
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		fmt.Printf("Sending combined prompt for video %d to LLM, attempt %d...\n", videoIndex, attempt+1)
+// Transient and rate-limit errors are retried per the retry policy; permanent errors (auth, invalid
+// argument, safety block, context too long, daily budget) fail fast. Failures are returned as *LLMError.
+// Every attempt waits for the quota limiter first. A response cut off at MAX_TOKENS is continued
+// up to maxContinuations times. The finish reason, block reason and safety ratings of the call are
+// logged and kept for the run report; chunkNum is -1 for whole-video calls.
+// When the stage's primary model is unavailable, out of quota or keeps failing, the call is sent
+// once more to its fallback model; with a fallback, rate-limit errors switch over without retrying.
+func (c *llmCaller) sentLlmPrompt(ctx context.Context, models *stageModel, prompt []genai.Part, file *os.File, videoIndex int, stage string, chunkNum int) (string, error) {
+	record := LLMCallRecord{Stage: stage, VideoIndex: videoIndex, ChunkNum: chunkNum, Model: models.name}
+	defer func() { c.recordCall(record) }()
+	ctx = withLogAttrs(ctx, logKeyStage, stage)
+	logger := logFrom(ctx)
+	model := models.primary
+
+	var promptTokens, reserved int64
+	if c.quota.countsTokens() {
+		// Pre-flight count, so the per-minute token bucket and daily budget see the real prompt size
+		tokens, err := countPromptTokens(ctx, model, prompt)
+		if err != nil {
+			llmErr := classifyLLMError(ctx, err)
+			if llmErr.Kind == LLMErrorCancelled || llmErr.Kind == LLMErrorAuth {
+				record.noteError(llmErr)
+				return "", llmErr
+			}
+			logger.Warn("Token count failed, sending without a pre-flight count", "error", err)
+		}
+		promptTokens = tokens
+		if err := c.quota.Reserve(promptTokens); err != nil {
+			llmErr := &LLMError{Kind: LLMErrorBudgetExceeded, Err: err}
+			record.noteError(llmErr)
+			return "", llmErr
+		}
+		reserved = promptTokens
+		defer func() { c.quota.Release(reserved) }()
+	}
+
+	logger.Debug("Sending prompt to LLM", "model", models.name, "prompt_tokens", promptTokens)
+	send := func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+		return model.GenerateContent(ctx, prompt...)
+	}
+	resp, err := c.generate(ctx, promptTokens, &record, models.fallback == nil, send)
+	var llmErr *LLMError
+	if err != nil && models.fallback != nil && errors.As(err, &llmErr) && llmErr.canFallBack() {
+		logger.Warn("Model failed, falling back", "model", models.name, "fallback", models.fallbackName, "error", err)
+		model = models.fallback
+		record.Model = models.fallbackName
+		record.FellBack = true
+		resp, err = c.generate(ctx, promptTokens, &record, true, send)
+	}
+	if err != nil {
+		record.noteError(err)
+		if record.BlockReason != "" || record.FinishReason != "" {
+			logger.Error("LLM call was blocked", "finish_reason", record.FinishReason, "block_reason", record.BlockReason, "safety_ratings", record.flaggedRatings())
+		}
+		return "", err
+	}
+	llmResponse, err := c.continueResponse(ctx, resp, &record, &reserved, func(ctx context.Context, soFar string) (*genai.GenerateContentResponse, error) {
+		chat := model.StartChat()
+		chat.History = []*genai.Content{
+			{Role: "user", Parts: prompt},
+			{Role: "model", Parts: []genai.Part{genai.Text(soFar)}},
+		}
+		return c.generate(ctx, promptTokens, &record, true, func(ctx context.Context) (*genai.GenerateContentResponse, error) {
+			return chat.SendMessage(ctx, genai.Text(continuePrompt))
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	writeResponse(ctx, file, llmResponse)
+
+	switch {
+	case record.FinishReason != "" && record.FinishReason != genai.FinishReasonStop.String() && !record.Truncated:
+		logger.Warn("LLM response finished abnormally", "finish_reason", record.FinishReason)
+	case llmResponse == "":
+		logger.Warn("LLM returned an empty response", "finish_reason", record.FinishReason)
+	}
+	if flagged := record.flaggedRatings(); flagged != "" {
+		logger.Warn("LLM response has elevated safety ratings", "safety_ratings", flagged)
+	}
+	logger.Debug("LLM prompt processed", "model", record.Model, "prompt_tokens", record.PromptTokens, "response_tokens", record.ResponseTokens)
+	return llmResponse, nil
+}
+
+// generate sends one request via send, retrying transient errors, and rate-limit errors when
+// retryRateLimits is set, with backoff. Attempts are counted in record.
+func (c *llmCaller) generate(ctx context.Context, promptTokens int64, record *LLMCallRecord, retryRateLimits bool, send func(context.Context) (*genai.GenerateContentResponse, error)) (*genai.GenerateContentResponse, error) {
+	logger := logFrom(ctx)
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForQuota(ctx, promptTokens); err != nil {
+			return nil, classifyLLMError(ctx, err)
+		}
+		record.Attempts++
 		startTime := time.Now()
-		resp, err := model.GenerateContent(ctx, prompt...)
+		resp, err := send(ctx)
 		if err == nil {
-			duration := time.Since(startTime)
-			fmt.Printf("LLM response received for video %d in %v.\n", videoIndex, duration)
-			var llmResponse string
-			for _, c := range resp.Candidates {
-				if c.Content != nil {
-					for _, part := range c.Content.Parts {
-						if text, ok := part.(genai.Text); ok {
-							llmResponse += string(text)
-							if file != nil { // Check if file is nil before writing
-								if _, err := fmt.Fprintln(file, string(text)); err != nil {
-									log.Println("Error writing to file:", err)
-								}
-							}
-						}
-					}
-				}
-			}
-			fmt.Printf("Combined prompt processed and written to file for video %d.\n", videoIndex)
-			return llmResponse
+			logger.Debug("LLM response received", "duration", time.Since(startTime), "attempt", attempt+1)
+			return resp, nil
 		}
 
-		log.Printf("Error generating content for video %d (attempt %d): %v\n", videoIndex, attempt+1, err)
-		if attempt < maxRetries {
-			fmt.Printf("Retrying in %v...\n", retryDelay)
-			time.Sleep(retryDelay)
-		} else {
-			fmt.Printf("Max retries reached for video %d. Aborting LLM call.\n", videoIndex)
-			return "" // Return empty string if max retries reached
+		llmErr := classifyLLMError(ctx, err)
+		llmErr.Attempts = attempt + 1
+		logger.Warn("Error generating content", "attempt", attempt+1, "kind", llmErr.Kind, "error", err)
+		if !llmErr.Retryable() || (llmErr.Kind == LLMErrorRateLimited && !retryRateLimits) {
+			return nil, llmErr
+		}
+		if attempt >= c.retry.MaxRetries {
+			logger.Error("Max retries reached, aborting LLM call", "attempts", attempt+1)
+			return nil, llmErr
+		}
+
+		delay := c.retry.delay(attempt, llmErr.RetryAfter)
+		logger.Info("Retrying LLM call", "delay", delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			logger.Info("Cancelled while waiting to retry LLM call")
+			return nil, classifyLLMError(ctx, ctx.Err())
+		case <-time.After(delay):
 		}
 	}
-	return "" // Should not reach here, but added for completeness
 }
 
-// chunkVideo function
-func chunkVideo(videoPath string, chunkDuration int, videoIndex int, baseName string) ([]ChunkData, error) {
-	_, err := exec.LookPath("ffmpeg")
+// waitForQuota blocks until a request carrying promptTokens may be sent
+func (c *llmCaller) waitForQuota(ctx context.Context, promptTokens int64) error {
+	if err := c.quota.WaitRequest(ctx); err != nil {
+		return err
+	}
+	return c.quota.WaitTokens(ctx, int(promptTokens))
+}
+
+// probeDuration returns the duration of a media file in seconds
+func probeDuration(ctx context.Context, path string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "quiet", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path)
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("ffmpeg not found in PATH: %w", err)
+		return 0, fmt.Errorf("error getting video duration: %w, output: %s", err, string(output))
 	}
 
-	tempDir, err := os.MkdirTemp("", "video_chunks")
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
 	if err != nil {
-		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+		return 0, fmt.Errorf("error parsing video duration: %w", err)
 	}
+	return duration, nil
+}
 
-	cmd := exec.Command("ffprobe", "-v", "quiet", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", videoPath)
-	output, err := cmd.CombinedOutput()
+// chunkVideo function
+// Chunks are written to tempDir, which the caller owns and removes.
+// audioChannels is the channel count of the extracted WAV chunks; 0 skips audio extraction.
+func chunkVideo(ctx context.Context, videoPath string, tempDir string, chunkDuration int, videoIndex int, baseName string, audioChannels int, progress *videoProgress, observer Observer) ([]ChunkData, error) {
+	_, err := exec.LookPath("ffmpeg")
 	if err != nil {
-		os.RemoveAll(tempDir)
-		return nil, fmt.Errorf("error getting video duration: %w, output: %s", err, string(output))
+		return nil, fmt.Errorf("ffmpeg not found in PATH: %w", err)
 	}
 
-	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	duration, err := probeDuration(ctx, videoPath)
 	if err != nil {
-		os.RemoveAll(tempDir)
-		return nil, fmt.Errorf("error parsing video duration: %w", err)
+		return nil, err
 	}
 
 	numChunks := int(duration / float64(chunkDuration))
 	if int(duration)%chunkDuration != 0 {
 		numChunks++
 	}
+	progress.start(ProgressStageChunking, numChunks)
 
 	var chunks []ChunkData
 
@@ -407,32 +640,51 @@ func chunkVideo(videoPath string, chunkDuration int, videoIndex int, baseName st
 		chunkVideoPath := fmt.Sprintf("%s/chunk_%d_video_%d.mp4", tempDir, i, videoIndex)
 		chunkAudioPath := fmt.Sprintf("%s/chunk_%d_video_%d.wav", tempDir, i, videoIndex)
 
-		cmd := exec.Command("ffmpeg",
+		cmdArgs := []string{
 			"-ss", fmt.Sprintf("%d", startTime),
 			"-i", videoPath,
 			"-t", fmt.Sprintf("%d", chunkDuration),
 			"-c", "copy",
 			"-an", chunkVideoPath,
-			"-ss", fmt.Sprintf("%d", startTime),
-			"-i", videoPath,
-			"-t", fmt.Sprintf("%d", chunkDuration),
-			"-vn",
-			"-acodec", "pcm_s16le", // 16-bit WAV audio
-			chunkAudioPath,
-		)
+		}
+		if audioChannels > 0 {
+			cmdArgs = append(cmdArgs,
+				"-ss", fmt.Sprintf("%d", startTime),
+				"-i", videoPath,
+				"-t", fmt.Sprintf("%d", chunkDuration),
+				"-vn",
+				"-acodec", "pcm_s16le", // 16-bit WAV audio
+				"-ar", fmt.Sprintf("%d", whisperSampleRate), // whisper.cpp expects 16kHz
+				"-ac", fmt.Sprintf("%d", audioChannels),
+				chunkAudioPath,
+			)
+		} else {
+			chunkAudioPath = ""
+		}
+		cmd := exec.CommandContext(ctx, "ffmpeg", cmdArgs...)
 
-		output, err = cmd.CombinedOutput()
+		output, err := cmd.CombinedOutput()
 		if err != nil {
 			return nil, fmt.Errorf("error creating video chunk %d for video %d: %w, output: %s", i, videoIndex, err, string(output))
 		}
-		chunks = append(chunks, ChunkData{VideoPath: chunkVideoPath, AudioPath: chunkAudioPath, ChunkNum: i, VideoIndex: videoIndex, BaseName: baseName})
+		chunks = append(chunks, ChunkData{VideoPath: chunkVideoPath, AudioPath: chunkAudioPath, ChunkNum: i, VideoIndex: videoIndex, BaseName: baseName, Offset: time.Duration(startTime) * time.Second, Source: videoPath})
+		progress.done(ProgressStageChunking)
+		observer.OnChunkCreated(chunks[len(chunks)-1].info())
 	}
 
 	return chunks, nil
 }
 
 // TranscribeAudioWhisperCLI function
-func TranscribeAudioWhisperCLI(audioPath string, whisperCLIPath string, whisperModelPath string, videoIndex int, chunkNum int, threads int, language string) (string, error) {
+func TranscribeAudioWhisperCLI(ctx context.Context, audioPath string, whisperCLIPath string, whisperModelPath string, videoIndex int, chunkNum int, threads int, language string) (string, error) {
+	transcript, _, _, err := runWhisperCLI(ctx, audioPath, whisperCLIPath, whisperModelPath, videoIndex, chunkNum, threads, language, false, "")
+	return transcript, err
+}
+
+// runWhisperCLI runs whisper-cli on one chunk and returns its stdout (the transcript), stderr
+// (where it logs the auto-detected language) and the CPU time it used. translate makes whisper
+// translate to English. diarize is one of the Diarization* modes, or empty for none.
+func runWhisperCLI(ctx context.Context, audioPath string, whisperCLIPath string, whisperModelPath string, videoIndex int, chunkNum int, threads int, language string, translate bool, diarize string) (string, string, time.Duration, error) {
 	cmdArgs := []string{
 		"--model", whisperModelPath,
 		"--threads", fmt.Sprintf("%d", threads),
@@ -440,38 +692,80 @@ func TranscribeAudioWhisperCLI(audioPath string, whisperCLIPath string, whisperM
 	if language != "" {
 		cmdArgs = append(cmdArgs, "--language", language)
 	}
+	if translate {
+		cmdArgs = append(cmdArgs, "--translate")
+	}
+	switch diarize {
+	case DiarizationTinydiarize:
+		cmdArgs = append(cmdArgs, "--tinydiarize")
+	case DiarizationStereo:
+		cmdArgs = append(cmdArgs, "--diarize")
+	}
 	cmdArgs = append(cmdArgs, audioPath)
 
-	cmd := exec.Command(whisperCLIPath, cmdArgs...)
+	cmd := exec.CommandContext(ctx, whisperCLIPath, cmdArgs...)
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
 
-	fmt.Printf("Starting whisper-cli for video %d chunk %d, Audio Path: %s\n", videoIndex, chunkNum, audioPath)
+	logFrom(ctx).Debug("Starting whisper-cli", "audio_path", audioPath)
 	startTime := time.Now()
 
 	err := cmd.Run()
 	duration := time.Since(startTime)
-	fmt.Printf("Whisper-cli finished for video %d chunk %d in %v\n", videoIndex, chunkNum, duration)
+	logFrom(ctx).Debug("Whisper-cli finished", "duration", duration)
 
 	if err != nil {
-		return "", fmt.Errorf("error running whisper-cli for video %d chunk %d: %w, stderr: %s", videoIndex, chunkNum, err, stderr.String())
+		return "", "", 0, fmt.Errorf("error running whisper-cli for video %d chunk %d: %w, stderr: %s", videoIndex, chunkNum, err, stderr.String())
 	}
 
-	transcript := out.String()
-	return transcript, nil
+	cpuTime := cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	return out.String(), stderr.String(), cpuTime, nil
+}
+
+var maxVolumeRegex = regexp.MustCompile(`max_volume:\s*(-?inf|-?[0-9.]+) dB`)
+
+// detectSilentAudio runs ffmpeg volumedetect on an audio chunk and reports whether its peak
+// volume is at or below thresholdDB, i.e. whether running whisper on it would only produce [BLANK_AUDIO].
+func detectSilentAudio(ctx context.Context, audioPath string, thresholdDB float64) (bool, float64, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-nostats",
+		"-i", audioPath,
+		"-af", "volumedetect",
+		"-f", "null",
+		"-",
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false, 0, fmt.Errorf("error running volumedetect on %s: %w, output: %s", audioPath, err, string(output))
+	}
+
+	match := maxVolumeRegex.FindStringSubmatch(string(output))
+	if len(match) < 2 {
+		// No audio stream statistics at all (e.g. empty stream): nothing for whisper to hear.
+		return true, math.Inf(-1), nil
+	}
+	if strings.HasSuffix(match[1], "inf") {
+		return true, math.Inf(-1), nil
+	}
+	maxVolume, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("error parsing max_volume %q for %s: %w", match[1], audioPath, err)
+	}
+	return maxVolume <= thresholdDB, maxVolume, nil
 }
 
 // extractFrames function
-func extractFrames(videoPath string, videoIndex int, chunkNum int) ([]string, error) {
+func extractFrames(ctx context.Context, videoPath string, videoIndex int, chunkNum int) ([]string, error) {
 	tempDir, err := os.MkdirTemp("", fmt.Sprintf("frames_video%d_chunk%d", videoIndex, chunkNum))
 	if err != nil {
 		return nil, fmt.Errorf("error creating temporary directory for frames: %w", err)
 	}
 
 	// Extract frames at 1fps.  Adjust -r as needed.
-	cmd := exec.Command("ffmpeg",
+	cmd := exec.CommandContext(ctx, "ffmpeg",
 		"-i", videoPath,
 		"-r", "1", // Frames per second
 		"-q:v", "2", // JPEG quality (2 is high)
@@ -499,7 +793,7 @@ func extractFrames(videoPath string, videoIndex int, chunkNum int) ([]string, er
 }
 
 // TranscribeVideoTesseractAPIAPI function
-func TranscribeVideoTesseractAPI(framePaths []string) (string, error) {
+func TranscribeVideoTesseractAPI(ctx context.Context, framePaths []string) (string, error) {
 	var combinedTranscript strings.Builder
 	var wg sync.WaitGroup
 	frameResults := make(chan struct {
@@ -582,7 +876,7 @@ func TranscribeVideoTesseractAPI(framePaths []string) (string, error) {
 				return
 			}
 
-			cmd := exec.Command("tesseract", tempFilePath, "stdout")
+			cmd := exec.CommandContext(ctx, "tesseract", tempFilePath, "stdout")
 			var stdout, stderr bytes.Buffer
 			cmd.Stdout = &stdout
 			cmd.Stderr = &stderr
@@ -607,306 +901,637 @@ func TranscribeVideoTesseractAPI(framePaths []string) (string, error) {
 	wg.Wait()           // Wait for all goroutines to finish
 	close(frameResults) // Close the channel - no more results coming
 
-	// Collect results from the channel
+	// Collect results from the channel; unreadable frames are skipped unless none could be read
+	var failed []error
 	for result := range frameResults {
 		if result.Error != nil {
-			log.Println(result.Error) // Log individual errors
-			continue                  // Skip frames with errors
+			logFrom(ctx).Warn("Skipping frame", "error", result.Error)
+			failed = append(failed, result.Error)
+			continue
 		}
 		combinedTranscript.WriteString(result.Text)
 		combinedTranscript.WriteString("\n")
 	}
+	if len(failed) > 0 && len(failed) == len(framePaths) {
+		return "", fmt.Errorf("tesseract failed on all %d frames: %w", len(failed), failed[0])
+	}
 
 	return combinedTranscript.String(), nil
 }
 
 // transcribeVideoLLM function
-func transcribeVideoLLM(ctx context.Context, client *genai.Client, model *genai.GenerativeModel, videoPath string, videoIndex int, chunkNum int) (string, error) {
-	uploadedFile, err := client.UploadFileFromPath(ctx, videoPath, nil)
+// It falls back to Tesseract when the upload or the LLM transcription fails.
+func transcribeVideoLLM(ctx context.Context, client *genai.Client, llm *llmCaller, model *stageModel, videoPath string, videoIndex int, chunkNum int, stats *ChunkReport) (string, error) {
+	videoTranscript, err := uploadAndTranscribeVideo(ctx, client, llm, model, videoPath, videoIndex, chunkNum, "", stats)
+	if errors.Is(err, context.Canceled) {
+		return "", err
+	}
 	if err != nil {
 		// If LLM fails, fall back to Tesseract
-		fmt.Printf("Chunk %d for video %d: LLM upload failed, falling back to Tesseract...\n", chunkNum, videoIndex)
-		framePaths, err := extractFrames(videoPath, videoIndex, chunkNum)
-		if err != nil {
-			return "", fmt.Errorf("error extracting frames for video %d chunk %d: %w", videoIndex, chunkNum, err)
-		}
-		transcript, err := TranscribeVideoTesseractAPI(framePaths)
-		if err != nil {
-			return "", fmt.Errorf("error transcribing frames with Tesseract for video %d chunk %d: %w", videoIndex, chunkNum, err)
-		}
-
-		// Cleanup extracted frames.
-		if len(framePaths) > 0 {
-			os.RemoveAll(filepath.Dir(framePaths[0]))
-		}
-		return transcript, nil
+		logFrom(ctx).Warn("LLM video transcription failed, falling back to Tesseract", "error", err)
+		return transcribeVideoTesseract(ctx, videoPath, videoIndex, chunkNum, stats)
+	}
+	return videoTranscript, nil
+}
 
+// uploadAndTranscribeVideo uploads a chunk through the Files API and has the LLM transcribe the
+// text shown in it. ocrGrounding, when set, is Tesseract output passed along as a reference.
+func uploadAndTranscribeVideo(ctx context.Context, client *genai.Client, llm *llmCaller, model *stageModel, videoPath string, videoIndex int, chunkNum int, ocrGrounding string, stats *ChunkReport) (string, error) {
+	// Uploads count against the same requests-per-minute limit as GenerateContent
+	if err := llm.quota.WaitRequest(ctx); err != nil {
+		return "", err
 	}
+	uploadedFile, err := client.UploadFileFromPath(ctx, videoPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("LLM upload failed: %w", err)
+	}
+	defer deleteUploadedFile(ctx, client, uploadedFile.Name)
+	stats.addUpload(uploadedFile.SizeBytes)
 
-	fmt.Println("Waiting for 30 seconds after file upload to ensure file activation...")
-	time.Sleep(60 * time.Second) // Wait for file to be ready
-	defer func() { client.DeleteFile(ctx, uploadedFile.Name) }()
+	logFrom(ctx).Debug("Waiting for uploaded file to become active", "file", uploadedFile.Name)
+	if _, err := waitForFileActive(ctx, client, uploadedFile.Name, defaultFilePoll); err != nil {
+		return "", fmt.Errorf("uploaded file did not become active: %w", err)
+	}
 
-	fmt.Printf("Chunk %d for video %d: Video chunk uploaded as: %s\n", chunkNum, videoIndex, uploadedFile.URI)
+	logFrom(ctx).Debug("Video chunk uploaded", "uri", uploadedFile.URI, "bytes", uploadedFile.SizeBytes)
 
 	promptList := []genai.Part{
 		genai.FileData{URI: uploadedFile.URI},
 		genai.Text("## Task Description\nAnalyze the video and provide a detailed raw transcription of text displayed in the video."),
 	}
-	videoTranscript := sentLlmPrompt(model, promptList, ctx, nil, videoIndex) // No file writing here
-
+	if ocrGrounding != "" {
+		promptList = append(promptList, ocrGroundingPart(ocrGrounding))
+	}
+	videoTranscript, err := llm.sentLlmPrompt(ctx, model, promptList, nil, videoIndex, llmStageVideoTranscription, chunkNum) // No file writing here
+	if err != nil {
+		return "", fmt.Errorf("LLM transcription failed: %w", err)
+	}
 	if videoTranscript == "" {
-		// If LLM transcription fails, fall back to Tesseract
-		fmt.Printf("Chunk %d for video %d: LLM transcription failed, falling back to Tesseract...\n", chunkNum, videoIndex)
-		framePaths, err := extractFrames(videoPath, videoIndex, chunkNum)
-		if err != nil {
-			return "", fmt.Errorf("error extracting frames for video %d chunk %d: %w", videoIndex, chunkNum, err)
-		}
-		transcript, err := TranscribeVideoTesseractAPI(framePaths)
-		// Cleanup extracted frames.
-		if len(framePaths) > 0 {
-			os.RemoveAll(filepath.Dir(framePaths[0]))
-		}
-		if err != nil {
-			return "", fmt.Errorf("error transcribing frames with Tesseract for video %d chunk %d: %w", videoIndex, chunkNum, err)
-		}
-		return transcript, nil
+		return "", errors.New("LLM transcription is empty")
 	}
 
-	fmt.Printf("Chunk %d for video %d: Video transcribed by LLM.\n", chunkNum, videoIndex)
+	logFrom(ctx).Debug("Video transcribed by LLM")
 
 	return videoTranscript, nil
 }
 
+// uploadCleanupTimeout bounds deleting an upload once the run has been cancelled
+const uploadCleanupTimeout = 30 * time.Second
+
+// deleteUploadedFile removes a Gemini upload. It still runs when ctx has been cancelled
+// (Ctrl-C), so interrupted runs don't leave files behind on the remote side.
+func deleteUploadedFile(ctx context.Context, client *genai.Client, name string) {
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), uploadCleanupTimeout)
+	defer cancel()
+	if err := client.DeleteFile(cleanupCtx, name); err != nil {
+		logFrom(ctx).Warn("Failed to delete uploaded file", "file", name, "error", err)
+	}
+}
+
+// transcribeVideoTesseract is the OCR fallback for when the LLM cannot transcribe a chunk
+func transcribeVideoTesseract(ctx context.Context, videoPath string, videoIndex int, chunkNum int, stats *ChunkReport) (string, error) {
+	framePaths, err := extractFrames(ctx, videoPath, videoIndex, chunkNum)
+	if err != nil {
+		return "", fmt.Errorf("error extracting frames for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	stats.addTesseractFrames(len(framePaths))
+	transcript, err := TranscribeVideoTesseractAPI(ctx, framePaths)
+	// Cleanup extracted frames.
+	if len(framePaths) > 0 {
+		os.RemoveAll(filepath.Dir(framePaths[0]))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error transcribing frames with Tesseract for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	return transcript, nil
+}
+
+// chunkOutcome is what VideoSummary needs to know about a processed chunk when building the final prompt
+type chunkOutcome struct {
+	// AudioSilent is set when volumedetect found no audible sound and whisper was skipped
+	AudioSilent bool
+	// AudioLanguage is the configured or detected spoken language, empty if no audio was transcribed
+	AudioLanguage string
+	// AudioTranscript and VideoTranscript are the chunk's text as written to the output files
+	AudioTranscript string
+	VideoTranscript string
+}
+
 // processChunk function
-func processChunk(chunkData ChunkData, client *genai.Client, model *genai.GenerativeModel, ctx context.Context, errorChannel chan<- error, whisperCLIPath string, whisperModelPath string, whisperThreads int, whisperLanguage string, audioOutputFile, videoOutputFile *os.File) {
+// It reports whether the chunk's audio was silent, so the caller can fall back to a video-only
+// summary when a whole recording has no speech, and which language was spoken.
+// Resource use is recorded in stats.
+func processChunk(chunkData ChunkData, client *genai.Client, llm *llmCaller, model *stageModel, ctx context.Context, errs *errorCollector, cfg *SummaryConfig, audioOutputFile, videoOutputFile *os.File, srtOutput *srtWriter, stats *ChunkReport, progress *videoProgress) chunkOutcome {
 	chunk := chunkData
 
 	if chunk.Err != nil {
-		errorChannel <- chunk.Err
-		return
+		errs.chunkFailed(ctx, chunk, chunk.Err)
+		return chunkOutcome{}
 	}
 
-	fmt.Printf("Processing chunk %d for video %d...\n", chunk.ChunkNum, chunk.VideoIndex)
-	defer fmt.Printf("Finished processing chunk %d for video %d.\n", chunk.ChunkNum, chunk.VideoIndex)
+	ctx = withLogAttrs(ctx, logKeyChunk, chunk.ChunkNum)
+	logFrom(ctx).Info("Processing chunk")
+	defer logFrom(ctx).Info("Finished processing chunk")
 
 	var wg sync.WaitGroup
-	wg.Add(2) // We have two goroutines: audio and video transcription
 
-	var audioTranscript string
-	var audioErr error
-	go func() {
-		defer wg.Done()
-		audioTranscript, audioErr = TranscribeAudioWhisperCLI(chunk.AudioPath, whisperCLIPath, whisperModelPath, chunk.VideoIndex, chunk.ChunkNum, whisperThreads, whisperLanguage)
-		if audioErr != nil {
-			errorChannel <- fmt.Errorf("error transcribing audio for video %d chunk %d: %w", chunk.VideoIndex, chunk.ChunkNum, audioErr)
-			audioTranscript = fmt.Sprintf("Audio transcription failed for video %d chunk %d.", chunk.VideoIndex, chunk.ChunkNum)
-		}
-		// Write to audio output file *immediately*
-		_, err := fmt.Fprintf(audioOutputFile, "Video Index: %d, Chunk: %d\n%s\n", chunk.VideoIndex, chunk.ChunkNum, audioTranscript)
-		if err != nil {
-			errorChannel <- fmt.Errorf("error writing to audio file for video %d chunk %d: %v", chunk.VideoIndex, chunk.ChunkNum, err)
-		}
-		fmt.Printf("Chunk %d for video %d: Audio transcribed and written to audio output file.\n", chunk.ChunkNum, chunk.VideoIndex)
-		os.Remove(chunk.AudioPath) // Delete audio chunk
-	}()
+	var outcome chunkOutcome
+	if chunk.AudioPath != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outcome = transcribeAudioChunk(ctx, chunk, cfg, errs, audioOutputFile, srtOutput, stats)
+			progress.done(ProgressStageWhisper)
+		}()
+	}
 
 	var videoTranscript string
 	var videoErr error
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		videoTranscript, videoErr = transcribeVideoLLM(ctx, client, model, chunk.VideoPath, chunk.VideoIndex, chunk.ChunkNum)
+		startTime := time.Now()
+		switch {
+		case cfg.VideoOCR == VideoOCRHybrid:
+			videoTranscript, videoErr = transcribeVideoHybrid(ctx, client, llm, model, cfg, chunk, errs, stats)
+		case cfg.VideoTranscription == VideoTranscriptionKeyframes:
+			videoTranscript, videoErr = transcribeVideoKeyframes(ctx, llm, model, chunk.VideoPath, chunk.VideoIndex, chunk.ChunkNum, chunk.Offset, cfg.KeyframeBatchBytes, stats)
+		default:
+			videoTranscript, videoErr = transcribeVideoLLM(ctx, client, llm, model, chunk.VideoPath, chunk.VideoIndex, chunk.ChunkNum, stats)
+		}
+		stats.addVideo(time.Since(startTime))
 		if videoErr != nil {
-			errorChannel <- fmt.Errorf("error transcribing video for video %d chunk %d: %w", chunk.VideoIndex, chunk.ChunkNum, videoErr)
+			errs.chunkFailed(ctx, chunk, fmt.Errorf("error transcribing video for video %d chunk %d: %w", chunk.VideoIndex, chunk.ChunkNum, videoErr))
 			videoTranscript = fmt.Sprintf("Video transcription failed for video %d chunk %d.", chunk.VideoIndex, chunk.ChunkNum)
 		}
 		// Write to video output file *immediately*
 		_, err := fmt.Fprintf(videoOutputFile, "Video Index: %d, Chunk: %d\n%s\n", chunk.VideoIndex, chunk.ChunkNum, videoTranscript)
 		if err != nil {
-			errorChannel <- fmt.Errorf("error writing to video file for video %d chunk %d: %v", chunk.VideoIndex, chunk.ChunkNum, err)
+			errs.chunkFailed(ctx, chunk, fmt.Errorf("error writing to video file for video %d chunk %d: %v", chunk.VideoIndex, chunk.ChunkNum, err))
 		}
-		fmt.Printf("Chunk %d for video %d: Video transcribed and written to video output file.\n", chunk.ChunkNum, chunk.VideoIndex)
+		cfg.Observer.OnVideoTranscribed(ChunkVideoTranscript{ChunkInfo: chunk.info(), Text: videoTranscript})
+		logFrom(ctx).Debug("Video transcribed and written to video output file")
 		os.Remove(chunk.VideoPath) // Delete video chunk
+		progress.done(ProgressStageVideoTranscription)
 	}()
 
 	wg.Wait() // Wait for both goroutines to complete
 
+	outcome.VideoTranscript = videoTranscript
+	return outcome
 }
 
-func VideoSummary(llm string, apiKey string, chunkDuration int, whisperCLIPath string, whisperModelPath string, whisperThreads int, whisperLanguage string, inputPath string, inputFromUser string) error {
-	runtime.GOMAXPROCS(runtime.NumCPU())
-
-	client, model, ctx, err := SetLlmApi(llm, apiKey)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-
-	errorChannel := make(chan error, 10) // Buffered channel
-
-	var videoPaths []string
-	// Ensure inputPath is absolute BEFORE stat check
-	absInputPath, err := filepath.Abs(inputPath)
-	if err != nil {
-		// Use log.Fatalf as this is a critical starting point error
-		log.Fatalf("Error converting input path '%s' to absolute: %v\n", inputPath, err)
-	}
+// transcribeAudioChunk runs whisper on one audio chunk unless volumedetect shows it is silent,
+// and writes the result to the audio output and SRT files.
+func transcribeAudioChunk(ctx context.Context, chunk ChunkData, cfg *SummaryConfig, errs *errorCollector, audioOutputFile *os.File, srtOutput *srtWriter, stats *ChunkReport) chunkOutcome {
+	defer os.Remove(chunk.AudioPath) // Delete audio chunk
+	startTime := time.Now()
+	var whisperCPU time.Duration
+	defer func() { stats.addAudio(time.Since(startTime), whisperCPU) }()
 
-	fileInfo, err := os.Stat(absInputPath)
+	silent, maxVolume, err := detectSilentAudio(ctx, chunk.AudioPath, cfg.silenceThreshold())
 	if err != nil {
-		// The stat check should now use the absolute path
-		log.Fatalf("Error accessing input path '%s': %v\n", absInputPath, err)
+		// Not fatal: fall through to whisper as before
+		logFrom(ctx).Warn("Silence detection failed, running whisper anyway", "error", err)
 	}
 
-	// Use absInputPath consistently from now on
-	inputPath = absInputPath // Update inputPath to the absolute version
-
-	if fileInfo.IsDir() {
-		fmt.Println("Processing folder:", inputPath)
-		err = filepath.WalkDir(inputPath, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !d.IsDir() && IsVideoFile(path) {
-				absVidPath, err := filepath.Abs(path) // Ensure stored path is absolute
-				if err != nil {
-					log.Printf("Warning: Could not get absolute path for %s: %v", path, err)
-					videoPaths = append(videoPaths, path) // Add original as fallback
+	outcome := chunkOutcome{AudioSilent: silent}
+	var audioTranscript string
+	var segments []TranscriptSegment
+	if silent {
+		audioTranscript = "[SILENT AUDIO - whisper skipped]"
+		logFrom(ctx).Info("Audio is silent, skipping whisper", "max_volume_db", maxVolume)
+	} else {
+		transcript, err := cfg.AudioTranscriber.TranscribeAudio(ctx, chunk.AudioPath, chunk.VideoIndex, chunk.ChunkNum)
+		whisperCPU = transcript.CPUTime
+		if err != nil {
+			errs.chunkFailed(ctx, chunk, fmt.Errorf("error transcribing audio for video %d chunk %d: %w", chunk.VideoIndex, chunk.ChunkNum, err))
+			audioTranscript = fmt.Sprintf("Audio transcription failed for video %d chunk %d.", chunk.VideoIndex, chunk.ChunkNum)
+		} else {
+			segments = transcript.Segments
+			if cfg.Diarizer != nil {
+				if diarized, err := cfg.Diarizer.Diarize(chunk.AudioPath, segments); err != nil {
+					errs.chunkFailed(ctx, chunk, fmt.Errorf("error diarizing audio for video %d chunk %d: %w", chunk.VideoIndex, chunk.ChunkNum, err))
 				} else {
-					videoPaths = append(videoPaths, absVidPath)
+					segments = diarized
 				}
 			}
-			return nil
-		})
-		if err != nil {
-			log.Fatalf("Error walking directory: %v\n", err)
-		}
-	} else {
-		fmt.Println("Processing single file:", inputPath) // Already absolute
-		if IsVideoFile(inputPath) {
-			videoPaths = append(videoPaths, inputPath)
-		} else {
-			log.Printf("Warning: Input path '%s' is not a video file.\n", inputPath)
+			segments = renameSpeakers(segments, cfg.speakerNames)
+
+			audioTranscript = formatTranscriptSegments(segments)
+			outcome.AudioLanguage = transcript.Language
+			if err := srtOutput.WriteSegments(chunk.Offset, segments); err != nil {
+				errs.chunkFailed(ctx, chunk, fmt.Errorf("error writing to SRT file for video %d chunk %d: %v", chunk.VideoIndex, chunk.ChunkNum, err))
+			}
 		}
 	}
 
-	if len(videoPaths) == 0 {
-		fmt.Println("No video files found to process.")
-		return nil
+	// Write to audio output file *immediately*
+	header := fmt.Sprintf("Video Index: %d, Chunk: %d", chunk.VideoIndex, chunk.ChunkNum)
+	if outcome.AudioLanguage != "" {
+		header += ", Language: " + outcome.AudioLanguage
+		if cfg.WhisperTranslate {
+			header += " (translated to English)"
+		}
 	}
+	outcome.AudioTranscript = audioTranscript
+	_, err = fmt.Fprintf(audioOutputFile, "%s\n%s\n", header, audioTranscript)
+	if err != nil {
+		errs.chunkFailed(ctx, chunk, fmt.Errorf("error writing to audio file for video %d chunk %d: %v", chunk.VideoIndex, chunk.ChunkNum, err))
+	}
+	cfg.Observer.OnAudioTranscribed(ChunkAudioTranscript{ChunkInfo: chunk.info(), Text: audioTranscript, Segments: segments, Language: outcome.AudioLanguage, Silent: silent})
+	logFrom(ctx).Debug("Audio transcribed and written to audio output file")
+	return outcome
+}
 
-	for videoIndex, videoPath := range videoPaths {
-		// videoPath should now be absolute
-		videoDir := filepath.Dir(videoPath) // Get the directory of the video
-		baseName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+// VideoSummary function
+// Cancelling ctx stops the run: running ffmpeg/whisper/tesseract processes are killed,
+// temporary chunk directories are removed and Gemini uploads are deleted.
+func VideoSummary(ctx context.Context, llm string, apiKey string, chunkDuration int, whisperCLIPath string, whisperModelPath string, whisperThreads int, whisperLanguage string, inputPath string, inputFromUser string) error {
+	return VideoSummaryWithConfig(ctx, SummaryConfig{
+		LLM:              llm,
+		APIKey:           apiKey,
+		ChunkDuration:    chunkDuration,
+		WhisperCLIPath:   whisperCLIPath,
+		WhisperModelPath: whisperModelPath,
+		WhisperThreads:   whisperThreads,
+		WhisperLanguage:  whisperLanguage,
+		InputPath:        inputPath,
+		InputFromUser:    inputFromUser,
+	})
+}
 
-		// Create output files in the *same directory* as the video
-		outputFileName := filepath.Join(videoDir, baseName+"_output.txt")
-		audioOutputFileName := filepath.Join(videoDir, baseName+"_audio_output.txt")
-		videoOutputFileName := filepath.Join(videoDir, baseName+"_video_output.txt")
+// VideoSummaryWithConfig is VideoSummary with the full set of options
+func VideoSummaryWithConfig(ctx context.Context, cfg SummaryConfig) error {
+	_, err := SummarizeVideos(ctx, cfg)
+	return err
+}
 
-		fmt.Printf("\n--- START PROCESSING VIDEO %d: %s ---\n", videoIndex+1, videoPath)
-		fmt.Printf("Creating output files in directory: %s\n", videoDir)
+// SummarizeVideos is VideoSummaryWithConfig returning a Result for every video it processed,
+// including failed ones. cfg.Observer, when set, sees each step as it happens.
+// A video that fails does not stop the batch: the error joins every video's *PipelineError and
+// unreadable folder entries. Only cancellation, an auth error or a spent daily budget stop the
+// run early, as every later video would fail the same way.
+func SummarizeVideos(ctx context.Context, cfg SummaryConfig) ([]*Result, error) {
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	inputPath := cfg.InputPath
+	if cfg.Logger != nil {
+		ctx = withLogger(ctx, cfg.Logger)
+	}
+	logger := logFrom(ctx)
+	if cfg.Observer == nil {
+		cfg.Observer = NopObserver{}
+	}
 
-		outputFile, err := os.Create(outputFileName)
-		if err != nil {
-			log.Fatalf("Error creating output file for video %s: %v\n", videoPath, err)
-			continue // Continue to the next video
-		}
-		defer outputFile.Close()
+	switch cfg.VideoTranscription {
+	case "", VideoTranscriptionUpload, VideoTranscriptionKeyframes:
+	default:
+		return nil, fmt.Errorf("unknown video transcription mode %q", cfg.VideoTranscription)
+	}
+	switch cfg.VideoOCR {
+	case "", VideoOCRFallback, VideoOCRHybrid:
+	default:
+		return nil, fmt.Errorf("unknown OCR mode %q", cfg.VideoOCR)
+	}
+	if err := cfg.Generation.validate(); err != nil {
+		return nil, fmt.Errorf("invalid generation config: %w", err)
+	}
+	client, _, err := SetLlmApi(ctx, cfg.LLM, cfg.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	models := newTaskModels(client, &cfg)
 
-		audioOutputFile, err := os.Create(audioOutputFileName)
-		if err != nil {
-			log.Fatalf("Error creating audio output file for video %s: %v\n", videoPath, err)
-			continue
+	if cfg.SpeakerNamesPath != "" {
+		if cfg.Diarization == DiarizationTinydiarize && cfg.Diarizer == nil {
+			return nil, fmt.Errorf("speaker names need speaker identities, which diarization %q does not give; use %q or a Diarizer", DiarizationTinydiarize, DiarizationStereo)
 		}
-		defer audioOutputFile.Close()
-
-		videoOutputFile, err := os.Create(videoOutputFileName)
-		if err != nil {
-			log.Fatalf("Error creating video output file for video %s: %v\n", videoPath, err)
-			continue
+		if cfg.speakerNames, err = loadSpeakerNames(cfg.SpeakerNamesPath); err != nil {
+			return nil, err
 		}
-		defer videoOutputFile.Close()
-		fmt.Println("Output files created for video:", videoPath)
+	}
+	audioChannels := 1
+	switch cfg.Diarization {
+	case "", DiarizationTinydiarize:
+	case DiarizationStereo:
+		audioChannels = 2 // --diarize tells speakers apart by channel
+	default:
+		return nil, fmt.Errorf("unknown diarization mode %q", cfg.Diarization)
+	}
+	if cfg.VideoOnly {
+		audioChannels = 0
+	}
 
-		fmt.Println("Chunking video sequentially...")
-		// Pass the absolute videoPath to chunkVideo
-		chunks, err := chunkVideo(videoPath, chunkDuration, videoIndex+1, baseName)
+	if cfg.AudioTranscriber == nil && !cfg.VideoOnly {
+		transcriber, err := newAudioTranscriber(&cfg)
 		if err != nil {
-			log.Printf("Error chunking video %s: %v\n", videoPath, err)
-			continue
+			return nil, err
 		}
-		fmt.Println("Video chunking complete.")
+		defer transcriber.Close()
+		cfg.AudioTranscriber = transcriber
+	}
 
-		fmt.Println("Processing video chunks in parallel...")
-		// No more slices needed here
+	llm, err := newLLMCaller(&cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, chunkData := range chunks {
-			processChunk(chunkData, client, model, ctx, errorChannel, whisperCLIPath, whisperModelPath, whisperThreads, whisperLanguage, audioOutputFile, videoOutputFile)
-		}
+	// failures collects what went wrong per video (and per unreadable folder entry), so one
+	// broken file does not stop the batch
+	videoPaths, failures, err := findVideos(logger, inputPath, cfg.folderScan())
+	if err != nil {
+		return nil, err
+	}
 
-		fmt.Println("All video chunks processed. Sending combined prompt to LLM...")
+	if len(videoPaths) == 0 {
+		logger.Warn("No video files found to process")
+		return nil, errors.Join(failures...)
+	}
+	llm.quota.warnIfRunExceedsBudget(ctx, videoPaths)
+	defer llm.quota.Flush(ctx)
+	progress := newRunProgress(cfg.Progress, len(videoPaths))
 
-		// Read the *entire* content of the audio and video files.
-		audioContent, err := os.ReadFile(audioOutputFileName)
+	prices := cfg.Prices
+	if prices == nil {
+		prices = DefaultPriceTable
+	}
+	var results []*Result
+	for videoIndex, videoPath := range videoPaths {
+		report := newVideoReport(videoPath, videoIndex+1)
+		result := &Result{VideoPath: videoPath, VideoIndex: videoIndex + 1, Report: report}
+		errs := &errorCollector{observer: cfg.Observer, report: report}
+		videoProgress := progress.video(videoPath, videoIndex+1)
+		err := summarizeVideo(ctx, client, llm, models, &cfg, errs, videoIndex, videoPath, audioChannels, report, videoProgress, result)
+		videoProgress.finish(err)
+		result.Errors = errs.errors()
+		if err == nil {
+			if srcErr := report.setSource(videoPath); srcErr != nil {
+				logger.Warn("Could not record the video's content hash", logKeyVideo, videoPath, "error", srcErr)
+			}
+		}
+		report.finish(err, llm.takeCalls(videoIndex+1), prices)
+		if reportErr := report.write(); reportErr != nil {
+			logger.Warn("Failed to write report", logKeyVideo, videoPath, "error", reportErr)
+		} else {
+			result.Files.Report = reportPath(videoPath)
+		}
 		if err != nil {
-			log.Printf("Error reading audio output file: %v", err)
-			continue // Crucial: Continue to the next video if reading fails
+			result.Err = err
+			videoErr := &PipelineError{VideoPath: videoPath, VideoIndex: videoIndex + 1, ChunkNum: -1, Err: err}
+			failures = append(failures, videoErr)
+			cfg.Observer.OnError(videoErr)
+		}
+		cfg.Observer.OnVideoDone(result)
+		if err == nil || ctx.Err() == nil {
+			notifyVideoDone(ctx, cfg.Notifiers, result)
+		}
+		results = append(results, result)
+		if ctx.Err() != nil {
+			logger.Warn("Run cancelled, stopping")
+			if !errors.Is(err, ctx.Err()) {
+				failures = append(failures, ctx.Err())
+			}
+			return results, errors.Join(failures...)
+		}
+		if errors.Is(err, ErrLLMAuth) || errors.Is(err, ErrDailyBudgetExceeded) {
+			// Every later video would fail the same way
+			return results, errors.Join(failures...)
 		}
-		videoContent, err := os.ReadFile(videoOutputFileName)
 		if err != nil {
-			log.Printf("Error reading video output file: %v", err)
-			continue
+			logger.Error("Error processing video", logKeyVideo, videoPath, "error", err)
 		}
-		combinedAudioTranscript := string(audioContent) // Convert to string
-		combinedVideoTranscript := string(videoContent)
-
-		var promptTemplate string
-		if inputFromUser != "" {
-			promptTemplate = `Context from user about this video: %s
-	Here is a raw transcription of a video. Your task is to refine it into a well-structured, human-like summary with explanations while keeping all the original details. Analyze the lecture provided in the audio transcription and video text. Identify the main topic, key arguments, supporting evidence, and any examples used, highlighting the connections between different ideas. Use information from both the audio transcription and video text to create a comprehensive explanation, also use timestamp to help us correlate with the audio transcript:
+	}
+	logger.Info("All videos processing complete", "videos", len(videoPaths), "failures", len(failures))
+	return results, errors.Join(failures...)
 
-    --- RAW TRANSCRIPTION of Audio ---
-    %s
+}
 
-    --- RAW TRANSCRIPTION of Video Text ---
-    %s
+// summarizeVideo chunks, transcribes and summarizes one video, writing the output files next to it
+// Stage timings and per-chunk resource use are recorded in report.
+func summarizeVideo(ctx context.Context, client *genai.Client, llm *llmCaller, models taskModels, cfg *SummaryConfig, errs *errorCollector, videoIndex int, videoPath string, audioChannels int, report *VideoReport, progress *videoProgress, result *Result) error {
+	// videoPath should now be absolute
+	videoDir := filepath.Dir(videoPath) // Get the directory of the video
+	baseName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+
+	// Create output files in the *same directory* as the video
+	outputFileName := filepath.Join(videoDir, baseName+"_output.txt")
+	audioOutputFileName := filepath.Join(videoDir, baseName+"_audio_output.txt")
+	srtOutputFileName := filepath.Join(videoDir, baseName+"_audio_output.srt")
+	videoOutputFileName := filepath.Join(videoDir, baseName+"_video_output.txt")
+
+	ctx = withLogAttrs(ctx, logKeyVideo, videoPath, logKeyVideoIndex, videoIndex+1)
+	ctx, closeLog, err := openVideoLog(ctx, videoPath, cfg.LogJSON)
+	if err != nil {
+		logFrom(ctx).Warn("Could not create the video log file", "path", videoLogPath(videoPath), "error", err)
+	} else {
+		result.Files.Log = videoLogPath(videoPath)
+	}
+	defer closeLog()
+	logger := logFrom(ctx)
 
-    Please rewrite it clearly with explanations where needed, ensuring it's easy to read and understand.`
-		} else {
-			promptTemplate = `Here is a raw transcription of a video. Your task is to refine it into a well-structured, human-like summary with explanations while keeping all the original details. Analyze the lecture provided in the audio transcription and video text. Identify the main topic, key arguments, supporting evidence, and any examples used, highlighting the connections between different ideas. Use information from both the audio transcription and video text to create a comprehensive explanation, also use timestamp to help us correlate with the audio transcript:
+	logger.Info("Start processing video")
+	logger.Debug("Creating output files", "dir", videoDir)
 
-    --- RAW TRANSCRIPTION of Audio ---
-    %s
+	outputFile, err := os.Create(outputFileName)
+	if err != nil {
+		return fmt.Errorf("error creating output file for video %s: %w", videoPath, err)
+	}
+	defer outputFile.Close()
 
-    --- RAW TRANSCRIPTION of Video Text ---
-    %s
+	audioOutputFile, err := os.Create(audioOutputFileName)
+	if err != nil {
+		return fmt.Errorf("error creating audio output file for video %s: %w", videoPath, err)
+	}
+	defer audioOutputFile.Close()
 
-    Please rewrite it clearly with explanations where needed, ensuring it's easy to read and understand.`
+	var srtOutput *srtWriter
+	if !cfg.VideoOnly {
+		srtOutputFile, err := os.Create(srtOutputFileName)
+		if err != nil {
+			return fmt.Errorf("error creating SRT output file for video %s: %w", videoPath, err)
 		}
+		defer srtOutputFile.Close()
+		srtOutput = newSRTWriter(srtOutputFile)
+	}
 
-		combinedPromptText := fmt.Sprintf(promptTemplate, inputFromUser, combinedAudioTranscript, combinedVideoTranscript)
+	videoOutputFile, err := os.Create(videoOutputFileName)
+	if err != nil {
+		return fmt.Errorf("error creating video output file for video %s: %w", videoPath, err)
+	}
+	defer videoOutputFile.Close()
+	result.Files.Summary = outputFileName
+	result.Files.AudioTranscript = audioOutputFileName
+	result.Files.VideoTranscript = videoOutputFileName
+	if srtOutput != nil {
+		result.Files.Subtitles = srtOutputFileName
+	}
 
-		combinedPrompt := []genai.Part{
-			genai.Text(combinedPromptText),
+	chunkDir, err := os.MkdirTemp("", "video_chunks")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(chunkDir)
+
+	logger.Info("Chunking video")
+	// Pass the absolute videoPath to chunkVideo
+	stageStart := time.Now()
+	chunks, err := chunkVideo(ctx, videoPath, chunkDir, cfg.ChunkDuration, videoIndex+1, baseName, audioChannels, progress, cfg.Observer)
+	report.timeStage(stageChunking, stageStart)
+	if err != nil {
+		return fmt.Errorf("error chunking video %s: %w", videoPath, err)
+	}
+	logger.Info("Video chunking complete", "chunks", len(chunks))
+
+	silentChunks := 0
+	var spokenLanguages []string
+	outcomes := make([]chunkOutcome, len(chunks))
+	stageStart = time.Now()
+	if audioChannels > 0 {
+		progress.start(ProgressStageWhisper, len(chunks))
+	}
+	progress.start(ProgressStageVideoTranscription, len(chunks))
+	for i, chunkData := range chunks {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		outcome := processChunk(chunkData, client, llm, models.VideoTranscription, ctx, errs, cfg, audioOutputFile, videoOutputFile, srtOutput, report.chunk(chunkData.ChunkNum), progress)
+		outcomes[i] = outcome
+		if outcome.AudioSilent {
+			silentChunks++
+		}
+		if outcome.AudioLanguage != "" && outcome.AudioLanguage != AutoDetectLanguage && !slices.Contains(spokenLanguages, outcome.AudioLanguage) {
+			spokenLanguages = append(spokenLanguages, outcome.AudioLanguage)
+		}
+	}
 
-		sentLlmPrompt(model, combinedPrompt, ctx, outputFile, videoIndex+1) // Now passing the file
-		fmt.Printf("\n--- FINISHED PROCESSING VIDEO %d: %s ---\n", videoIndex+1, videoPath)
-		fmt.Fprintf(outputFile, "\n--- VIDEO %d PROCESSING COMPLETE ---\n\n", videoIndex+1)
-		fmt.Fprintf(audioOutputFile, "\n--- VIDEO %d PROCESSING COMPLETE ---\n\n", videoIndex+1)
-		fmt.Fprintf(videoOutputFile, "\n--- VIDEO %d PROCESSING COMPLETE ---\n\n", videoIndex+1)
+	report.timeStage(stageTranscription, stageStart)
+	result.Chunks = make([]ChunkResult, len(chunks))
+	for i, chunk := range chunks {
+		result.Chunks[i] = ChunkResult{
+			ChunkInfo:       chunk.info(),
+			AudioTranscript: outcomes[i].AudioTranscript,
+			AudioLanguage:   outcomes[i].AudioLanguage,
+			AudioSilent:     outcomes[i].AudioSilent,
+			VideoTranscript: outcomes[i].VideoTranscript,
+		}
 	}
-	close(errorChannel) // Close *after* the loop, *before* reading
-	for err := range errorChannel {
-		log.Println("Error from goroutine:", err)
+
+	// A recording with no audible chunk at all is a silent screencast: summarize the visuals only
+	videoOnly := cfg.VideoOnly
+	if !videoOnly && len(chunks) > 0 && silentChunks == len(chunks) {
+		logger.Info("All chunks are silent, summarizing the visual transcript only", "chunks", len(chunks))
+		videoOnly = true
 	}
 
-	fmt.Println("\nAll videos processing complete.")
-	fmt.Println("Exiting.")
+	logger.Info("All video chunks processed, generating the summary")
+
+	// Read the *entire* content of the audio and video files.
+	audioContent, err := os.ReadFile(audioOutputFileName)
+	if err != nil {
+		return fmt.Errorf("error reading audio output file: %w", err)
+	}
+	videoContent, err := os.ReadFile(videoOutputFileName)
+	if err != nil {
+		return fmt.Errorf("error reading video output file: %w", err)
+	}
+	combinedAudioTranscript := string(audioContent) // Convert to string
+	combinedVideoTranscript := string(videoContent)
+
+	promptInput := summaryPromptInput{
+		InputFromUser:   cfg.InputFromUser,
+		AudioTranscript: combinedAudioTranscript,
+		VideoTranscript: combinedVideoTranscript,
+		VideoOnly:       videoOnly,
+		SourceLanguage:  strings.Join(spokenLanguages, ", "),
+		Translated:      cfg.WhisperTranslate,
+		Diarized:        cfg.Diarization == DiarizationStereo || cfg.Diarizer != nil,
+		SpeakerTurns:    cfg.Diarization == DiarizationTinydiarize && cfg.Diarizer == nil,
+		SummaryLanguage: cfg.SummaryLanguage,
+		HybridOCR:       cfg.VideoOCR == VideoOCRHybrid,
+	}
+	summaryCalls := 1
+	if cfg.ChunkSummaries {
+		summaryCalls += len(chunks)
+	}
+	progress.start(ProgressStageSummary, summaryCalls)
+	if cfg.ChunkSummaries {
+		stageStart = time.Now()
+		chunkSummariesFileName := filepath.Join(videoDir, baseName+"_chunk_summaries.txt")
+		result.Files.ChunkSummaries = chunkSummariesFileName
+		chunkSummaries, err := summarizeChunks(ctx, llm, models.ChunkSummary, promptInput, result.Chunks, chunkSummariesFileName, videoIndex+1, progress, errs)
+		if err != nil {
+			return err
+		}
+		promptInput.ChunkSummaries = chunkSummaries
+		report.timeStage(stageChunkSummary, stageStart)
+	}
+	combinedPromptText := buildSummaryPrompt(promptInput)
+
+	combinedPrompt := []genai.Part{
+		genai.Text(combinedPromptText),
+	}
+
+	stageStart = time.Now()
+	summary, err := llm.sentLlmPrompt(ctx, models.FinalSummary, combinedPrompt, outputFile, videoIndex+1, llmStageSummary, -1) // Now passing the file
+	report.timeStage(stageFinalSummary, stageStart)
+	if err != nil {
+		return fmt.Errorf("error generating summary for video %d: %w", videoIndex+1, err)
+	}
+	result.Summary = summary
+	cfg.Observer.OnSummaryChunk(SummaryChunk{VideoPath: videoPath, VideoIndex: videoIndex + 1, ChunkNum: -1, Text: summary})
+	progress.done(ProgressStageSummary)
+	logger.Info("Finished processing video")
+	fmt.Fprintf(outputFile, "\n--- VIDEO %d PROCESSING COMPLETE ---\n\n", videoIndex+1)
+	fmt.Fprintf(audioOutputFile, "\n--- VIDEO %d PROCESSING COMPLETE ---\n\n", videoIndex+1)
+	fmt.Fprintf(videoOutputFile, "\n--- VIDEO %d PROCESSING COMPLETE ---\n\n", videoIndex+1)
 	return nil
+}
 
+// summarizeChunks summarizes every chunk on its own, writing the summaries to chunkSummariesPath,
+// and returns them joined for the final prompt. base carries the run-wide prompt settings.
+// Each summary is also stored in its ChunkResult. A chunk whose summary fails is passed on as
+// its raw transcripts.
+func summarizeChunks(ctx context.Context, llm *llmCaller, model *stageModel, base summaryPromptInput, chunks []ChunkResult, chunkSummariesPath string, videoIndex int, progress *videoProgress, errs *errorCollector) (string, error) {
+	chunkSummariesFile, err := os.Create(chunkSummariesPath)
+	if err != nil {
+		return "", fmt.Errorf("error creating chunk summaries file for video %d: %w", videoIndex, err)
+	}
+	defer chunkSummariesFile.Close()
+
+	logFrom(ctx).Info("Summarizing chunks", "chunks", len(chunks))
+	var combined strings.Builder
+	for i, chunk := range chunks {
+		in := base
+		in.AudioTranscript = chunk.AudioTranscript
+		in.VideoTranscript = chunk.VideoTranscript
+		prompt := []genai.Part{genai.Text(buildChunkSummaryPrompt(in, chunk.ChunkNum, chunk.Offset))}
+
+		header := fmt.Sprintf("Video Index: %d, Chunk: %d", videoIndex, chunk.ChunkNum)
+		fmt.Fprintln(chunkSummariesFile, header)
+		chunkCtx := withLogAttrs(ctx, logKeyChunk, chunk.ChunkNum)
+		summary, err := llm.sentLlmPrompt(chunkCtx, model, prompt, chunkSummariesFile, videoIndex, llmStageChunkSummary, chunk.ChunkNum)
+		fmt.Fprint(chunkSummariesFile, "\n\n")
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrLLMAuth) || errors.Is(err, ErrDailyBudgetExceeded) {
+			return "", fmt.Errorf("error summarizing chunk %d of video %d: %w", chunk.ChunkNum, videoIndex, err)
+		}
+		if err != nil || summary == "" {
+			if err != nil {
+				errs.add(chunkCtx, &PipelineError{VideoPath: chunk.VideoPath, VideoIndex: videoIndex, ChunkNum: chunk.ChunkNum, Err: fmt.Errorf("error summarizing chunk, using its raw transcripts instead: %w", err)})
+			} else {
+				logFrom(chunkCtx).Warn("Chunk summary is empty, using its raw transcripts instead")
+			}
+			var raw strings.Builder
+			writeRawTranscripts(&raw, in)
+			summary = raw.String()
+		} else {
+			chunks[i].Summary = summary
+			errs.observer.OnSummaryChunk(SummaryChunk{VideoPath: chunk.VideoPath, VideoIndex: videoIndex, ChunkNum: chunk.ChunkNum, Text: summary})
+		}
+		fmt.Fprintf(&combined, "%s\n%s\n\n", header, summary)
+		progress.done(ProgressStageSummary)
+	}
+	return combined.String(), nil
 }
 
 func IsUrl(str string) string {
@@ -920,26 +1545,188 @@ func IsUrl(str string) string {
 // main function
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serveMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "jobs" {
+		jobsMain(os.Args[2:])
+		return
+	}
+	// "watch" takes the same flags and arguments, with a folder as the input
+	args := os.Args[1:]
+	watchMode := len(args) > 0 && args[0] == "watch"
+	if watchMode {
+		args = args[1:]
+	}
 	// Use all available CPUs
 
-	if len(os.Args) != 9 {
-		fmt.Println("Usage: program <llm_model> <api_key> <chunk_duration_seconds> <whisper_cli_path> <whisper_model_path> <whisper_threads> <whisper_language> <video_path_or_folder_or_youtube_url>")
+	videoOnly := flag.Bool("video-only", false, "skip audio transcription and summarize only the text shown in the video")
+	silenceThreshold := flag.Float64("silence-threshold-db", defaultSilenceThresholdDB, "max volume in dB at or below which an audio chunk is treated as silent and whisper is skipped")
+	translate := flag.Bool("translate", false, "have whisper translate the audio transcript to English")
+	summaryLanguage := flag.String("summary-language", defaultSummaryLanguage, "language to write the final summary in, independent of the spoken language")
+	diarize := flag.String("diarize", "", "label speakers in the audio transcript: tinydiarize (needs a *-tdrz model) or stereo (speakers on separate channels)")
+	speakerNames := flag.String("speaker-names", "", "JSON file mapping speaker labels to names, e.g. {\"Speaker 1\": \"Alice\"}")
+	llmMaxRetries := flag.Int("llm-max-retries", DefaultRetryPolicy.MaxRetries, "retries for transient and rate-limited LLM errors (exponential backoff with jitter)")
+	rpm := flag.Int("rpm", 0, "max Gemini requests (generate calls and uploads) per minute; 0 is unlimited")
+	tpm := flag.Int("tpm", 0, "max Gemini prompt tokens per minute, counted with CountTokens before each call; 0 is unlimited")
+	dailyTokenBudget := flag.Int64("daily-token-budget", 0, "max Gemini tokens per day across runs; 0 is unlimited")
+	videoTranscription := flag.String("video-transcription", VideoTranscriptionUpload, "how the LLM reads each chunk: upload (whole MP4 via the Files API) or keyframes (deduplicated frames sent inline)")
+	ocrMode := flag.String("ocr", VideoOCRFallback, "how tesseract is used: fallback (only when the LLM fails) or hybrid (run on every chunk and give its text to the LLM as grounding)")
+	keyframeBatchBytes := flag.Int("keyframe-batch-bytes", defaultKeyframeBatchBytes, "max inline image bytes per request in keyframes mode")
+	transcriptionModel := flag.String("transcription-model", "", "model for per-chunk video transcription (default: <llm_model>), e.g. a cheap flash model")
+	summaryModel := flag.String("summary-model", "", "model for chunk and final summaries (default: <llm_model>)")
+	fallbackModel := flag.String("fallback-model", "", "model to retry a call with when the stage's model is unavailable or out of quota")
+	priceTable := flag.String("price-table", "", "JSON file with per-model prices in USD per million tokens, for the cost estimate (default: built-in list prices)")
+	generationConfig := flag.String("generation-config", "", "JSON file with per-task generation settings (video_transcription, chunk_summary, final_summary)")
+	chunkSummaries := flag.Bool("chunk-summaries", false, "summarize each chunk first and build the final summary from the chunk summaries (for long recordings)")
+	quotaState := flag.String("quota-state", "", "file tracking today's token usage for --daily-token-budget (default: user cache dir)")
+	whisperBackend := flag.String("whisper-backend", WhisperBackendCLI, "audio transcription backend: cli (fork whisper-cli per chunk), bindings (in-process whisper.cpp, needs -tags whisper) or server")
+	whisperServerURL := flag.String("whisper-server-url", "", "endpoint for the server backend, e.g. http://localhost:8080/inference or an OpenAI-compatible /v1/audio/transcriptions URL")
+	whisperServerModel := flag.String("whisper-server-model", "", "model name sent to the server backend (required by OpenAI-compatible endpoints, e.g. whisper-1)")
+	quiet := flag.Bool("quiet", false, "only log warnings and errors")
+	verbose := flag.Bool("verbose", false, "also log debug detail such as uploads, whisper runs and LLM attempts")
+	logJSON := flag.Bool("log-json", false, "write log records as JSON, on stderr and in the per-video log files")
+	progressMode := flag.String("progress", progressAuto, "progress display on stderr: auto (tty on a terminal, plain otherwise), tty, plain or off")
+	force := flag.Bool("force", false, "summarize every video of a folder, also those an earlier run already summarized")
+	include := flag.String("include", "", "comma-separated glob patterns; only videos of a folder matching one are summarized, e.g. '*.mp4,lectures/*'")
+	exclude := flag.String("exclude", "", "comma-separated glob patterns of videos and subfolders to leave out")
+	maxDepth := flag.Int("max-depth", 0, "how many folder levels to search for videos, 1 being the folder itself; 0 is unlimited")
+	buildNotifiers := notifierFlags(flag.CommandLine)
+	watchInterval := flag.Duration("watch-interval", defaultWatchInterval, "watch mode: how often the folder is scanned for new videos")
+	watchStableFor := flag.Duration("watch-stable-for", defaultWatchStableFor, "watch mode: how long a video's size must stay unchanged before it is summarized")
+	watchMoveTo := flag.String("watch-move-to", "", "watch mode: folder to move summarized videos and their outputs to (default: leave them and record them in "+watchStateFile+")")
+	flag.Usage = func() {
+		fmt.Println("Usage: program [watch] [flags] <llm_model> <api_key> <chunk_duration_seconds> <whisper_cli_path> <whisper_model_path> <whisper_threads> <whisper_language|auto> <video_path_or_folder_or_youtube_url>")
+		flag.PrintDefaults()
+		fmt.Println("\nRun \"program serve --help\" for the HTTP job API, and \"program jobs --help\" to manage its queue.")
+	}
+	flag.CommandLine.Parse(args)
+
+	if flag.NArg() != 8 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	llm := os.Args[1]
-	apiKey := os.Args[2]
-	chunkDuration, err := strconv.Atoi(os.Args[3])
+	llm := flag.Arg(0)
+	apiKey := flag.Arg(1)
+	chunkDuration, err := strconv.Atoi(flag.Arg(2))
 	if err != nil {
 		log.Fatalf("Invalid chunk duration: %v\n", err)
 	}
-	whisperCLIPath := os.Args[4]
-	whisperModelPath := os.Args[5]
-	whisperThreads, err := strconv.Atoi(os.Args[6])
+	whisperCLIPath := flag.Arg(3)
+	whisperModelPath := flag.Arg(4)
+	whisperThreads, err := strconv.Atoi(flag.Arg(5))
 	if err != nil {
 		log.Fatalf("Invalid whisper threads: %v\n", err)
 	}
-	whisperLanguage := os.Args[7]
-	inputPath := os.Args[8]
+	whisperLanguage := flag.Arg(6)
+	inputPath := flag.Arg(7)
+
+	if *progressMode == progressAuto {
+		*progressMode = progressPlain
+		if isTerminal(os.Stderr) {
+			*progressMode = progressTTY
+		}
+	}
+	var progress ProgressReporter
+	// Log lines go through the progress display, so they are not drawn over by it
+	var logOutput io.Writer = os.Stderr
+	switch *progressMode {
+	case progressTTY:
+		terminal := newTerminalProgress(os.Stderr)
+		progress, logOutput = terminal, terminal
+	case progressPlain:
+		progress = NewPlainProgress(os.Stderr)
+	case progressOff:
+	default:
+		log.Fatalf("Invalid progress mode %q\n", *progressMode)
+	}
+
+	logOpts := LogOptions{Level: slog.LevelInfo, JSON: *logJSON}
+	switch {
+	case *verbose:
+		logOpts.Level = slog.LevelDebug
+	case *quiet, *progressMode == progressTTY:
+		// Info lines would scroll the progress display away; they still go to the per-video log files
+		logOpts.Level = slog.LevelWarn
+	}
+	logger := NewLogger(logOutput, logOpts)
+	slog.SetDefault(logger)
+
+	cfg := SummaryConfig{
+		LLM:                llm,
+		APIKey:             apiKey,
+		ChunkDuration:      chunkDuration,
+		WhisperCLIPath:     whisperCLIPath,
+		WhisperModelPath:   whisperModelPath,
+		WhisperThreads:     whisperThreads,
+		WhisperLanguage:    whisperLanguage,
+		VideoOnly:          *videoOnly,
+		SilenceThresholdDB: silenceThreshold,
+		WhisperTranslate:   *translate,
+		SummaryLanguage:    *summaryLanguage,
+		Diarization:        *diarize,
+		SpeakerNamesPath:   *speakerNames,
+		WhisperBackend:     *whisperBackend,
+		WhisperServerURL:   *whisperServerURL,
+		WhisperServerModel: *whisperServerModel,
+		ChunkSummaries:     *chunkSummaries,
+		VideoTranscription: *videoTranscription,
+		KeyframeBatchBytes: *keyframeBatchBytes,
+		VideoOCR:           *ocrMode,
+		MaxDepth:           *maxDepth,
+		Force:              *force,
+		Logger:             logger,
+		LogJSON:            *logJSON,
+		Progress:           progress,
+		// Keep API keys off the command line where other users on the box can see them
+		WhisperServerAPIKey: os.Getenv("WHISPER_SERVER_API_KEY"),
+	}
+
+	// Ctrl-C / SIGTERM cancels the run; VideoSummary cleans up subprocesses, temp dirs and uploads
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Notifiers, err = buildNotifiers(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if cfg.Include, err = splitPatterns(*include); err != nil {
+		log.Fatalf("Invalid --include: %v\n", err)
+	}
+	if cfg.Exclude, err = splitPatterns(*exclude); err != nil {
+		log.Fatalf("Invalid --exclude: %v\n", err)
+	}
+	cfg.RetryPolicy = DefaultRetryPolicy
+	cfg.RetryPolicy.MaxRetries = *llmMaxRetries
+	cfg.Models = ModelRoutes{
+		VideoTranscription: ModelRoute{Model: *transcriptionModel, Fallback: *fallbackModel},
+		ChunkSummary:       ModelRoute{Model: *summaryModel, Fallback: *fallbackModel},
+		FinalSummary:       ModelRoute{Model: *summaryModel, Fallback: *fallbackModel},
+	}
+	if *priceTable != "" {
+		if cfg.Prices, err = LoadPriceTable(*priceTable); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	}
+	if *generationConfig != "" {
+		if cfg.Generation, err = LoadGenerationConfigs(*generationConfig); err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	}
+	cfg.Quota = QuotaConfig{
+		RequestsPerMinute: *rpm,
+		TokensPerMinute:   *tpm,
+		DailyTokenBudget:  *dailyTokenBudget,
+		StatePath:         *quotaState,
+	}
+
+	if watchMode {
+		err := WatchFolder(ctx, cfg, WatchConfig{Dir: inputPath, Interval: *watchInterval, StableFor: *watchStableFor, MoveTo: *watchMoveTo})
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+		return
+	}
 
 	if IsUrl(inputPath) == "url" {
 		// Determine absolute destination directory
@@ -951,14 +1738,14 @@ func main() {
 		destinationDir := getDestinationDir(filepath.Join(currentDir, "Videos"))
 		// No need to MkdirAll here, getDestinationDir/YoutubeDownloader handles it
 
-		log.Printf("Attempting download from URL: %s to Directory: %s\n", inputPath, destinationDir)
+		logger.Info("Downloading video", "url", inputPath, "dir", destinationDir)
 		// YoutubeDownloader now returns the guaranteed absolute path
-		absPath, err := YoutubeDownloader(inputPath, destinationDir)
+		absPath, err := YoutubeDownloader(ctx, inputPath, destinationDir)
 		if err != nil {
 			log.Fatalf("Error downloading YouTube video: %v\n", err)
 		}
 
-		log.Printf("Download complete. Video saved at absolute path: %s\n", absPath)
+		logger.Info("Download complete", "path", absPath)
 
 		// Verify file exists at the absolute path returned
 		if _, err := os.Stat(absPath); err != nil {
@@ -966,13 +1753,11 @@ func main() {
 			log.Fatalf("Downloaded video file not found or inaccessible at: %s. Error: %v\n", absPath, err)
 		}
 
-		log.Printf("File verified. Proceeding to process video: %s\n", absPath)
+		logger.Debug("File verified, proceeding to process video", "path", absPath)
 
 		// Pass the verified absolute path to VideoSummary
-		err = VideoSummary(llm, apiKey, chunkDuration, whisperCLIPath, whisperModelPath, whisperThreads, whisperLanguage, absPath, "")
-		if err != nil {
-			log.Fatalf("Error in VideoSummary: %v\n", err)
-		}
+		cfg.InputPath = absPath
+		runSummaries(ctx, cfg)
 
 	} else if IsUrl(inputPath) == "path" {
 		// For direct file paths, ensure we have absolute path
@@ -986,11 +1771,29 @@ func main() {
 			log.Fatalf("Input video file not found or inaccessible at: %s. Error: %v\n", absPath, err)
 		}
 
-		fmt.Printf("Processing local video file: %s\n", absPath)
-		err = VideoSummary(llm, apiKey, chunkDuration, whisperCLIPath, whisperModelPath, whisperThreads, whisperLanguage, absPath, "")
-		if err != nil {
-			log.Fatalf("Error in VideoSummary: %v\n", err)
-		}
+		logger.Info("Processing local video file", "path", absPath)
+		cfg.InputPath = absPath
+		runSummaries(ctx, cfg)
+	}
+}
+
+// runSummaries runs the CLI's batch and exits non-zero if any video failed
+func runSummaries(ctx context.Context, cfg SummaryConfig) {
+	results, err := SummarizeVideos(ctx, cfg)
+	var reports []*VideoReport
+	for _, result := range results {
+		reports = append(reports, result.Report)
+	}
+	if len(reports) > 0 {
+		fmt.Println("\nUsage summary (costs are estimates):")
+		printUsageSummary(os.Stdout, reports)
+	}
+	if len(results) > 1 {
+		fmt.Println("\nVideo status:")
+		printVideoStatus(os.Stdout, results)
+	}
+	if err != nil {
+		log.Fatalf("Error in VideoSummary: %v\n", err)
 	}
 }
 
@@ -0,0 +1,387 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats of a webhook notification
+const (
+	WebhookFormatJSON    = "json"
+	WebhookFormatSlack   = "slack"
+	WebhookFormatDiscord = "discord"
+)
+
+const (
+	// notifyTimeout bounds sending one notification, so a dead endpoint cannot hold up the batch
+	notifyTimeout = 30 * time.Second
+	// summaryExcerptRunes is how much of the summary a notification carries
+	summaryExcerptRunes = 500
+	// discordMaxContent is Discord's limit on a message's content
+	discordMaxContent = 2000
+)
+
+// Notification describes a finished video, as sent by a Notifier
+type Notification struct {
+	VideoPath  string `json:"video_path"`
+	VideoIndex int    `json:"video_index"`
+	// Status is "succeeded" or "failed", with Error holding why
+	Status         string      `json:"status"`
+	Error          string      `json:"error,omitempty"`
+	ChunkErrors    int         `json:"chunk_errors,omitempty"`
+	Files          OutputFiles `json:"files"`
+	SummaryExcerpt string      `json:"summary_excerpt,omitempty"`
+	// Summary is the full summary; it is attached to emails and left out of webhook payloads
+	Summary          string  `json:"-"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	ResponseTokens   int64   `json:"response_tokens"`
+}
+
+// Notifier is told about every video when it is finished, successfully or not. Errors are logged
+// and do not fail the video.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+func newNotification(r *Result) Notification {
+	n := Notification{
+		VideoPath:      r.VideoPath,
+		VideoIndex:     r.VideoIndex,
+		Status:         string(JobSucceeded),
+		ChunkErrors:    len(r.Errors),
+		Files:          r.Files,
+		SummaryExcerpt: excerpt(r.Summary, summaryExcerptRunes),
+		Summary:        r.Summary,
+	}
+	if r.Err != nil {
+		n.Status, n.Error = string(JobFailed), r.Err.Error()
+	}
+	if r.Report != nil {
+		u := r.Report.Usage
+		n.DurationSeconds, n.EstimatedCostUSD, n.PromptTokens, n.ResponseTokens = u.WallSeconds, u.EstimatedCostUSD, u.PromptTokens, u.ResponseTokens
+	}
+	return n
+}
+
+// title is the one-line description of the notification, e.g. an email subject
+func (n Notification) title() string {
+	if n.Error != "" {
+		return "Summary failed: " + filepath.Base(n.VideoPath)
+	}
+	return "Summary ready: " + filepath.Base(n.VideoPath)
+}
+
+// text renders the notification as a plain-text message
+func (n Notification) text() string {
+	var sb strings.Builder
+	sb.WriteString(n.title() + "\n")
+	if n.Error != "" {
+		fmt.Fprintf(&sb, "Error: %s\n", n.Error)
+	}
+	fmt.Fprintf(&sb, "Took %s, estimated cost $%.4f", secondsDuration(n.DurationSeconds), n.EstimatedCostUSD)
+	if n.ChunkErrors > 0 {
+		fmt.Fprintf(&sb, ", %d chunk error(s)", n.ChunkErrors)
+	}
+	sb.WriteString("\n")
+	if n.Files.Summary != "" {
+		fmt.Fprintf(&sb, "Summary: %s\n", n.Files.Summary)
+	}
+	if n.SummaryExcerpt != "" {
+		sb.WriteString("\n" + n.SummaryExcerpt + "\n")
+	}
+	return sb.String()
+}
+
+// excerpt returns the first max runes of s, marking a cut with an ellipsis
+func excerpt(s string, max int) string {
+	s = strings.TrimSpace(s)
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:max])) + "…"
+}
+
+// notifyVideoDone sends r to every notifier, logging the ones that fail
+func notifyVideoDone(ctx context.Context, notifiers []Notifier, r *Result) {
+	if len(notifiers) == 0 {
+		return
+	}
+	n := newNotification(r)
+	// A cancelled run still reports the videos it finished
+	ctx = context.WithoutCancel(ctx)
+	for _, notifier := range notifiers {
+		notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		if err := notifier.Notify(notifyCtx, n); err != nil {
+			logFrom(ctx).Warn("Failed to send notification", logKeyVideo, r.VideoPath, "error", err)
+		}
+		cancel()
+	}
+}
+
+// webhookNotifier posts notifications to a URL, as JSON or as a Slack or Discord message
+type webhookNotifier struct {
+	url        string
+	format     string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier returns a notifier posting to url. format is WebhookFormatJSON (the
+// Notification as JSON), WebhookFormatSlack or WebhookFormatDiscord (incoming-webhook
+// messages). A nil httpClient gets http.DefaultClient; requests are bounded by notifyTimeout.
+func NewWebhookNotifier(url string, format string, httpClient *http.Client) (Notifier, error) {
+	switch format {
+	case "":
+		format = WebhookFormatJSON
+	case WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDiscord:
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("webhook URL %q is not an http(s) URL", url)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &webhookNotifier{url: url, format: format, httpClient: httpClient}, nil
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	var payload any = n
+	switch w.format {
+	case WebhookFormatSlack:
+		payload = map[string]string{"text": n.text()}
+	case WebhookFormatDiscord:
+		payload = map[string]string{"content": excerpt(n.text(), discordMaxContent-1)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// SMTPConfig configures email notifications
+type SMTPConfig struct {
+	// Addr is the server's host:port; STARTTLS is used when the server offers it
+	Addr string
+	// Username and Password are sent with PLAIN auth when Username is set, which net/smtp only
+	// allows over TLS or to localhost
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+type emailNotifier struct {
+	cfg SMTPConfig
+}
+
+// NewEmailNotifier returns a notifier mailing each notification to cfg.To, with the summary
+// attached as a text file
+func NewEmailNotifier(cfg SMTPConfig) (Notifier, error) {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", cfg.Addr, err)
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("email notifications need a sender and at least one recipient")
+	}
+	return &emailNotifier{cfg: cfg}, nil
+}
+
+func (e *emailNotifier) Notify(ctx context.Context, n Notification) error {
+	msg, err := e.message(n)
+	if err != nil {
+		return err
+	}
+	if err := e.send(ctx, msg); err != nil {
+		if ctx.Err() != nil {
+			// The connection was closed under the client
+			err = ctx.Err()
+		}
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return nil
+}
+
+// send delivers msg as smtp.SendMail does. net/smtp takes no context, so the connection is
+// dialed here and closed when ctx ends, which stops a send to a stalled server.
+func (e *emailNotifier) send(ctx context.Context, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(e.cfg.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.cfg.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range e.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds a multipart email with the notification as the body and the summary attached
+func (e *emailNotifier) message(n Notification) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Quoted-printable keeps the UTF-8 text 7-bit and wraps lines longer than SMTP allows
+	qp := quotedprintable.NewWriter(text)
+	io.WriteString(qp, n.text())
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	if n.Summary != "" {
+		name := strings.TrimSuffix(filepath.Base(n.VideoPath), filepath.Ext(n.VideoPath)) + "_output.txt"
+		if n.Files.Summary != "" {
+			name = filepath.Base(n.Files.Summary)
+		}
+		attachment, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(n.Summary))
+		for len(encoded) > 76 {
+			io.WriteString(attachment, encoded[:76]+"\r\n")
+			encoded = encoded[76:]
+		}
+		io.WriteString(attachment, encoded+"\r\n")
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.title()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// notifierFlags registers the notification flags on fs; the returned function builds the
+// notifiers once fs is parsed. SMTP credentials come from SMTP_USERNAME and SMTP_PASSWORD, to
+// keep them off the command line.
+func notifierFlags(fs *flag.FlagSet) func() ([]Notifier, error) {
+	webhook := fs.String("notify-webhook", "", "URL to POST to when each video is finished")
+	webhookFormat := fs.String("notify-format", WebhookFormatJSON, "webhook payload: json (status, output paths, summary excerpt, cost), slack or discord (incoming-webhook messages)")
+	email := fs.String("notify-email", "", "comma-separated addresses to email each summary to, as an attachment; needs --smtp-addr and --smtp-from")
+	smtpAddr := fs.String("smtp-addr", "", "SMTP server host:port for --notify-email")
+	smtpFrom := fs.String("smtp-from", "", "sender address for --notify-email")
+	return func() ([]Notifier, error) {
+		var notifiers []Notifier
+		if *webhook != "" {
+			n, err := NewWebhookNotifier(*webhook, *webhookFormat, nil)
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, n)
+		}
+		if *email != "" {
+			var to []string
+			for _, addr := range strings.Split(*email, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					to = append(to, addr)
+				}
+			}
+			n, err := NewEmailNotifier(SMTPConfig{
+				Addr:     *smtpAddr,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     *smtpFrom,
+				To:       to,
+			})
+			if err != nil {
+				return nil, err
+			}
+			notifiers = append(notifiers, n)
+		}
+		return notifiers, nil
+	}
+}
@@ -0,0 +1,323 @@
+package videoSummaryGo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func testNotification() Notification {
+	return Notification{
+		VideoPath:        "/videos/talk.mp4",
+		VideoIndex:       2,
+		Status:           string(JobSucceeded),
+		ChunkErrors:      1,
+		Files:            OutputFiles{Summary: "/videos/talk_output.txt"},
+		SummaryExcerpt:   "A talk about Go.",
+		Summary:          "A talk about Go.\nIt covers goroutines, channels and ünïcode.",
+		DurationSeconds:  90,
+		EstimatedCostUSD: 0.0123,
+		PromptTokens:     1000,
+		ResponseTokens:   200,
+	}
+}
+
+// webhookRecorder is a test server recording the last request body and answering with status
+func webhookRecorder(t *testing.T, status int) (*httptest.Server, *[]byte) {
+	t.Helper()
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		io.WriteString(w, "rate limited\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func TestWebhookNotifierPayloads(t *testing.T) {
+	n := testNotification()
+	for _, format := range []string{WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDiscord} {
+		t.Run(format, func(t *testing.T) {
+			server, body := webhookRecorder(t, http.StatusNoContent)
+			notifier, err := NewWebhookNotifier(server.URL, format, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := notifier.Notify(context.Background(), n); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			switch format {
+			case WebhookFormatJSON:
+				var got map[string]any
+				if err := json.Unmarshal(*body, &got); err != nil {
+					t.Fatalf("invalid JSON %s: %v", *body, err)
+				}
+				if got["video_path"] != n.VideoPath || got["status"] != "succeeded" || got["summary_excerpt"] != n.SummaryExcerpt || got["prompt_tokens"] != 1000.0 {
+					t.Errorf("unexpected payload %s", *body)
+				}
+				if _, ok := got["Summary"]; ok {
+					t.Errorf("payload carries the full summary: %s", *body)
+				}
+			case WebhookFormatSlack, WebhookFormatDiscord:
+				key, want := "text", n.text()
+				if format == WebhookFormatDiscord {
+					key, want = "content", strings.TrimSpace(want)
+				}
+				var got map[string]string
+				if err := json.Unmarshal(*body, &got); err != nil {
+					t.Fatalf("invalid JSON %s: %v", *body, err)
+				}
+				if len(got) != 1 || got[key] != want {
+					t.Errorf("got %s, want only %q with the notification text", *body, key)
+				}
+				if !strings.HasPrefix(got[key], "Summary ready: talk.mp4\n") {
+					t.Errorf("message %q does not start with the title", got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierErrorStatus(t *testing.T) {
+	server, _ := webhookRecorder(t, http.StatusTooManyRequests)
+	notifier, err := NewWebhookNotifier(server.URL, WebhookFormatJSON, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = notifier.Notify(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("got error %v, want the status and response body", err)
+	}
+}
+
+func TestWebhookNotifierDiscordTruncation(t *testing.T) {
+	server, body := webhookRecorder(t, http.StatusOK)
+	notifier, err := NewWebhookNotifier(server.URL, WebhookFormatDiscord, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := testNotification()
+	n.Status, n.Error = string(JobFailed), strings.Repeat("é", 3*discordMaxContent)
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(*body, &got); err != nil {
+		t.Fatal(err)
+	}
+	content := got["content"]
+	if count := utf8.RuneCountInString(content); count > discordMaxContent {
+		t.Errorf("content has %d characters, Discord allows %d", count, discordMaxContent)
+	}
+	if !strings.HasPrefix(content, "Summary failed: talk.mp4\n") || !strings.HasSuffix(content, "…") {
+		t.Errorf("content is not the cut message: %q…%q", content[:40], content[len(content)-10:])
+	}
+}
+
+func TestNewWebhookNotifierValidation(t *testing.T) {
+	if _, err := NewWebhookNotifier("https://example.com/hook", "teams", nil); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if _, err := NewWebhookNotifier("ftp://example.com/hook", WebhookFormatJSON, nil); err == nil {
+		t.Error("non-http URL accepted")
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	n := testNotification()
+	// Long enough to need several base64 lines
+	n.Summary = strings.Repeat(n.Summary+"\n", 10)
+	// A single line longer than an SMTP line may be
+	n.SummaryExcerpt = strings.Repeat("Ünïcode summary text. ", 60)
+	notifier, err := NewEmailNotifier(SMTPConfig{Addr: "localhost:25", From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := notifier.(*emailNotifier).message(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	if to := msg.Header.Get("To"); to != "a@example.com, b@example.com" {
+		t.Errorf("To is %q", to)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Summary ready: talk.mp4" {
+		t.Errorf("Subject is %q", subject)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type is %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	text, err := reader.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc := text.Header.Get("Content-Transfer-Encoding"); enc != "quoted-printable" {
+		t.Errorf("text encoding is %q", enc)
+	}
+	rawText, _ := io.ReadAll(text)
+	// 7-bit lines within the 76 characters quoted-printable allows, far below SMTP's 998
+	for _, line := range strings.Split(string(rawText), "\r\n") {
+		if len(line) > 76 || strings.ContainsFunc(line, func(r rune) bool { return r > 127 }) {
+			t.Errorf("text part has line %q", line)
+		}
+	}
+	textBody, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(rawText)))
+	if err != nil {
+		t.Fatalf("invalid quoted-printable: %v", err)
+	}
+	if !strings.Contains(string(textBody), "Summary ready: talk.mp4\r\n") || !strings.Contains(string(textBody), n.SummaryExcerpt) {
+		t.Errorf("text part is %q", textBody)
+	}
+
+	attachment, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := attachment.FileName(); name != "talk_output.txt" {
+		t.Errorf("attachment is named %q", name)
+	}
+	if enc := attachment.Header.Get("Content-Transfer-Encoding"); enc != "base64" {
+		t.Errorf("attachment encoding is %q", enc)
+	}
+	encoded, _ := io.ReadAll(attachment)
+	for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("base64 line of %d characters", len(line))
+		}
+	}
+	decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, strings.NewReader(strings.ReplaceAll(string(encoded), "\r\n", ""))))
+	if err != nil {
+		t.Fatalf("invalid base64: %v", err)
+	}
+	if string(decoded) != n.Summary {
+		t.Errorf("attachment decodes to %q, want the summary", decoded)
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("unexpected third part: %v", err)
+	}
+}
+
+// fakeSMTPServer accepts one connection and speaks enough SMTP to take a message, which it
+// sends on the returned channel. With stall set it never greets, and the channel gets "" once
+// the client has closed the connection.
+func fakeSMTPServer(t *testing.T, stall bool) (string, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if stall {
+			io.Copy(io.Discard, r)
+			got <- ""
+			return
+		}
+		io.WriteString(conn, "220 localhost ESMTP\r\n")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO":
+				io.WriteString(conn, "250-localhost\r\n250 8BITMIME\r\n")
+			case "DATA":
+				io.WriteString(conn, "354 go ahead\r\n")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				io.WriteString(conn, "250 queued\r\n")
+				got <- data.String()
+			case "QUIT":
+				io.WriteString(conn, "221 bye\r\n")
+				return
+			default:
+				io.WriteString(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestEmailNotifierSends(t *testing.T) {
+	addr, got := fakeSMTPServer(t, false)
+	notifier, err := NewEmailNotifier(SMTPConfig{Addr: addr, From: "bot@example.com", To: []string{"a@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	select {
+	case data := <-got:
+		if !strings.Contains(data, "Subject: ") || !strings.Contains(data, "Content-Transfer-Encoding: quoted-printable") {
+			t.Errorf("server got %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server got no message")
+	}
+}
+
+func TestEmailNotifierTimeoutClosesConnection(t *testing.T) {
+	addr, closed := fakeSMTPServer(t, true)
+	notifier, err := NewEmailNotifier(SMTPConfig{Addr: addr, From: "bot@example.com", To: []string{"a@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = notifier.Notify(ctx, testNotification())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("returned after %v, want the 50ms timeout", elapsed)
+	}
+	// The send was stopped, not left running against the server
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("the connection was left open")
+	}
+}
@@ -0,0 +1,184 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Observer receives pipeline events as they happen, e.g. to stream results to a UI or database.
+// Methods are called from the processing goroutines, possibly concurrently for the audio and
+// video of a chunk, and must not block. Embed NopObserver to implement only some of them.
+type Observer interface {
+	// OnChunkCreated is called when ffmpeg has cut a chunk
+	OnChunkCreated(ChunkInfo)
+	// OnAudioTranscribed is called when a chunk's audio is transcribed, or found silent
+	OnAudioTranscribed(ChunkAudioTranscript)
+	// OnVideoTranscribed is called when the text shown in a chunk is transcribed
+	OnVideoTranscribed(ChunkVideoTranscript)
+	// OnSummaryChunk is called with each chunk summary (with --chunk-summaries) and with the final summary
+	OnSummaryChunk(SummaryChunk)
+	// OnError is called for every error, whether it fails the video or only part of a chunk
+	OnError(*PipelineError)
+	// OnVideoDone is called when a video is finished, successfully or not
+	OnVideoDone(*Result)
+}
+
+// NopObserver ignores every event
+type NopObserver struct{}
+
+func (NopObserver) OnChunkCreated(ChunkInfo)                {}
+func (NopObserver) OnAudioTranscribed(ChunkAudioTranscript) {}
+func (NopObserver) OnVideoTranscribed(ChunkVideoTranscript) {}
+func (NopObserver) OnSummaryChunk(SummaryChunk)             {}
+func (NopObserver) OnError(*PipelineError)                  {}
+func (NopObserver) OnVideoDone(*Result)                     {}
+
+// ChunkInfo identifies a chunk of a video
+type ChunkInfo struct {
+	// VideoPath is the source video; VideoIndex counts from 1
+	VideoPath  string
+	VideoIndex int
+	ChunkNum   int
+	// Offset is where the chunk starts in the video
+	Offset time.Duration
+}
+
+func (c ChunkData) info() ChunkInfo {
+	return ChunkInfo{VideoPath: c.Source, VideoIndex: c.VideoIndex, ChunkNum: c.ChunkNum, Offset: c.Offset}
+}
+
+// ChunkAudioTranscript is a chunk's audio transcript as written to _audio_output.txt
+type ChunkAudioTranscript struct {
+	ChunkInfo
+	Text string
+	// Segments are the timed, speaker-labelled segments; empty when the audio was silent or failed
+	Segments []TranscriptSegment
+	// Language is the configured or detected spoken language
+	Language string
+	Silent   bool
+}
+
+// ChunkVideoTranscript is the text shown in a chunk, as written to _video_output.txt
+type ChunkVideoTranscript struct {
+	ChunkInfo
+	Text string
+}
+
+// SummaryChunk is a piece of summary text
+type SummaryChunk struct {
+	VideoPath  string
+	VideoIndex int
+	// ChunkNum is the chunk a chunk summary covers, or -1 for the final summary
+	ChunkNum int
+	Text     string
+}
+
+// PipelineError is an error attributed to the video, and chunk if any, it happened in
+type PipelineError struct {
+	VideoPath  string
+	VideoIndex int
+	// ChunkNum is -1 for errors that are not about a single chunk
+	ChunkNum int
+	Err      error
+}
+
+func (e *PipelineError) Error() string {
+	if e.ChunkNum < 0 {
+		return fmt.Sprintf("video %d (%s): %v", e.VideoIndex, e.VideoPath, e.Err)
+	}
+	return fmt.Sprintf("video %d (%s) chunk %d: %v", e.VideoIndex, e.VideoPath, e.ChunkNum, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// Result is what summarizing one video produced
+type Result struct {
+	VideoPath string
+	// VideoIndex counts from 1
+	VideoIndex int
+	// Summary is the final summary, also written to Files.Summary
+	Summary string
+	Chunks  []ChunkResult
+	Files   OutputFiles
+	Report  *VideoReport
+	// Err is why the video failed, nil if it was summarized
+	Err error
+	// Errors are the failures of single chunks, e.g. a chunk whose audio could not be transcribed.
+	// The video is still summarized from the remaining transcripts.
+	Errors []*PipelineError
+}
+
+// ChunkResult holds a chunk's transcripts
+type ChunkResult struct {
+	ChunkInfo
+	AudioTranscript string
+	AudioLanguage   string
+	AudioSilent     bool
+	VideoTranscript string
+	// Summary is the chunk summary, set with SummaryConfig.ChunkSummaries
+	Summary string
+}
+
+// OutputFiles lists the files written for a video; files that were not written are empty
+type OutputFiles struct {
+	Summary         string `json:"summary,omitempty"`
+	AudioTranscript string `json:"audio_transcript,omitempty"`
+	Subtitles       string `json:"subtitles,omitempty"`
+	VideoTranscript string `json:"video_transcript,omitempty"`
+	ChunkSummaries  string `json:"chunk_summaries,omitempty"`
+	Report          string `json:"report,omitempty"`
+	Log             string `json:"log,omitempty"`
+}
+
+// printVideoStatus prints one line per video saying whether it was summarized
+func printVideoStatus(w io.Writer, results []*Result) {
+	for _, r := range results {
+		status := "ok"
+		if len(r.Errors) > 0 {
+			status = fmt.Sprintf("ok, %d chunk error(s), see %s", len(r.Errors), filepath.Base(reportPath(r.VideoPath)))
+		}
+		if r.Err != nil {
+			status = "FAILED: " + r.Err.Error()
+		}
+		fmt.Fprintf(w, "[%d] %s: %s\n", r.VideoIndex, filepath.Base(r.VideoPath), status)
+	}
+}
+
+// errorCollector gathers the errors of a video's chunks from its worker goroutines. Adding an
+// error never blocks, however many chunks fail. Each error is logged, passed to the observer
+// and recorded in the chunk's report.
+type errorCollector struct {
+	observer Observer
+	report   *VideoReport
+
+	mu   sync.Mutex
+	errs []*PipelineError
+}
+
+// chunkFailed records err against chunk
+func (c *errorCollector) chunkFailed(ctx context.Context, chunk ChunkData, err error) {
+	c.add(ctx, &PipelineError{VideoPath: chunk.Source, VideoIndex: chunk.VideoIndex, ChunkNum: chunk.ChunkNum, Err: err})
+}
+
+func (c *errorCollector) add(ctx context.Context, e *PipelineError) {
+	logFrom(ctx).Error("Chunk failed", "error", e.Err)
+	c.report.chunk(e.ChunkNum).addError(e.Err)
+	c.mu.Lock()
+	c.errs = append(c.errs, e)
+	c.mu.Unlock()
+	c.observer.OnError(e)
+}
+
+// errors returns the errors collected so far
+func (c *errorCollector) errors() []*PipelineError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.errs)
+}
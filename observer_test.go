@@ -0,0 +1,91 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingObserver counts OnError calls
+type countingObserver struct {
+	NopObserver
+	errors atomic.Int64
+}
+
+func (o *countingObserver) OnError(*PipelineError) { o.errors.Add(1) }
+
+func TestErrorCollectorConcurrentChunkFailures(t *testing.T) {
+	const (
+		goroutines = 400
+		chunks     = 50
+	)
+	ctx := withLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	observer := &countingObserver{}
+	report := newVideoReport("video.mp4", 1)
+	collector := &errorCollector{observer: observer, report: report}
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chunk := ChunkData{Source: "video.mp4", VideoIndex: 1, ChunkNum: i%chunks + 1}
+			collector.chunkFailed(ctx, chunk, fmt.Errorf("failure %d", i))
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("chunkFailed blocked")
+	}
+
+	errs := collector.errors()
+	if len(errs) != goroutines {
+		t.Fatalf("errors() returned %d errors, want %d", len(errs), goroutines)
+	}
+	if n := observer.errors.Load(); n != goroutines {
+		t.Errorf("observer got %d errors, want %d", n, goroutines)
+	}
+	seen := map[string]bool{}
+	for _, e := range errs {
+		var i int
+		if _, err := fmt.Sscanf(e.Err.Error(), "failure %d", &i); err != nil {
+			t.Fatalf("unexpected error %q", e.Err)
+		}
+		if want := i%chunks + 1; e.ChunkNum != want {
+			t.Errorf("%q has ChunkNum %d, want %d", e.Err, e.ChunkNum, want)
+		}
+		if e.VideoPath != "video.mp4" || e.VideoIndex != 1 {
+			t.Errorf("%q has video %s #%d", e.Err, e.VideoPath, e.VideoIndex)
+		}
+		seen[e.Err.Error()] = true
+	}
+	if len(seen) != goroutines {
+		t.Errorf("got %d distinct errors, want %d", len(seen), goroutines)
+	}
+
+	if len(report.Chunks) != chunks {
+		t.Fatalf("report has %d chunks, want %d", len(report.Chunks), chunks)
+	}
+	for _, c := range report.Chunks {
+		if len(c.Errors) != goroutines/chunks {
+			t.Errorf("chunk %d report has %d errors, want %d", c.ChunkNum, len(c.Errors), goroutines/chunks)
+		}
+		for _, msg := range c.Errors {
+			var i int
+			if _, err := fmt.Sscanf(msg, "failure %d", &i); err != nil || i%chunks+1 != c.ChunkNum {
+				t.Errorf("chunk %d report has error %q of another chunk", c.ChunkNum, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// OCR modes selectable through SummaryConfig.VideoOCR
+const (
+	// VideoOCRFallback runs Tesseract only when the LLM cannot transcribe a chunk (default)
+	VideoOCRFallback = "fallback"
+	// VideoOCRHybrid runs Tesseract on every chunk and gives its text to the LLM as grounding.
+	// Both transcripts are kept in _video_output.txt.
+	VideoOCRHybrid = "hybrid"
+)
+
+// Provenance headers for the two transcripts of a hybrid chunk in _video_output.txt
+const (
+	hybridLLMHeader       = "[Source: LLM transcription, grounded on Tesseract OCR]"
+	hybridTesseractHeader = "[Source: Tesseract OCR]"
+)
+
+const ocrGroundingPrompt = `## OCR Reference
+Below is Tesseract OCR output for frames of the same video. It is literal but noisy: it may contain recognition errors, broken layout and text from several frames. Use it to check code, formulas, numbers and names in your transcription and prefer its characters wherever they are clearly legible; do not copy its recognition errors.
+
+`
+
+func ocrGroundingPart(ocr string) genai.Part {
+	return genai.Text(ocrGroundingPrompt + ocr)
+}
+
+// transcribeVideoHybrid runs Tesseract on a chunk's frames, then the LLM (upload or keyframes
+// mode) with the Tesseract text as grounding, and returns both transcripts labelled by source.
+func transcribeVideoHybrid(ctx context.Context, client *genai.Client, llm *llmCaller, model *stageModel, cfg *SummaryConfig, chunk ChunkData, errs *errorCollector, stats *ChunkReport) (string, error) {
+	framePaths, err := extractFrames(ctx, chunk.VideoPath, chunk.VideoIndex, chunk.ChunkNum)
+	if err != nil {
+		return "", fmt.Errorf("error extracting frames for video %d chunk %d: %w", chunk.VideoIndex, chunk.ChunkNum, err)
+	}
+	if len(framePaths) > 0 {
+		defer os.RemoveAll(filepath.Dir(framePaths[0]))
+	}
+
+	stats.addTesseractFrames(len(framePaths))
+	ocr := func(ctx context.Context) (string, error) {
+		return TranscribeVideoTesseractAPI(ctx, framePaths)
+	}
+	transcribe := func(ctx context.Context, ocrGrounding string) (string, error) {
+		if cfg.VideoTranscription == VideoTranscriptionKeyframes {
+			return transcribeKeyframesLLM(ctx, llm, model, framePaths, chunk.VideoIndex, chunk.ChunkNum, chunk.Offset, cfg.KeyframeBatchBytes, ocrGrounding)
+		}
+		return uploadAndTranscribeVideo(ctx, client, llm, model, chunk.VideoPath, chunk.VideoIndex, chunk.ChunkNum, ocrGrounding, stats)
+	}
+	return runHybridOCR(ctx, chunk, errs, ocr, transcribe)
+}
+
+// runHybridOCR runs ocr, then transcribe grounded on its text. A side that fails is recorded in
+// errs and in its section of the transcript, and the other side's text is kept. Both failing,
+// cancellation and LLM errors that fail every later call too (auth, daily budget) are returned.
+func runHybridOCR(ctx context.Context, chunk ChunkData, errs *errorCollector, ocr func(context.Context) (string, error), transcribe func(ctx context.Context, ocrGrounding string) (string, error)) (string, error) {
+	ocrText, ocrErr := ocr(ctx)
+	if errors.Is(ocrErr, context.Canceled) {
+		return "", ocrErr
+	}
+	if ocrErr != nil {
+		logFrom(ctx).Warn("Tesseract failed, sending to the LLM without grounding", "error", ocrErr)
+	}
+
+	llmText, llmErr := transcribe(ctx, ocrText)
+	if errors.Is(llmErr, context.Canceled) || errors.Is(llmErr, ErrLLMAuth) || errors.Is(llmErr, ErrDailyBudgetExceeded) {
+		return "", llmErr
+	}
+	if llmErr != nil && ocrErr != nil {
+		return "", fmt.Errorf("hybrid OCR failed for video %d chunk %d: LLM: %w; Tesseract: %w", chunk.VideoIndex, chunk.ChunkNum, llmErr, ocrErr)
+	}
+	if llmErr != nil {
+		errs.chunkFailed(ctx, chunk, fmt.Errorf("LLM transcription failed for video %d chunk %d, keeping the Tesseract OCR: %w", chunk.VideoIndex, chunk.ChunkNum, llmErr))
+	}
+	if ocrErr != nil {
+		errs.chunkFailed(ctx, chunk, fmt.Errorf("tesseract OCR failed for video %d chunk %d, keeping the LLM transcription: %w", chunk.VideoIndex, chunk.ChunkNum, ocrErr))
+	}
+	logFrom(ctx).Debug("Video transcribed by LLM and Tesseract")
+	return formatHybridTranscript(llmText, llmErr, ocrText, ocrErr), nil
+}
+
+// formatHybridTranscript labels each transcript with its source, so reviewers can check
+// disputed content against the literal OCR
+func formatHybridTranscript(llmText string, llmErr error, ocr string, ocrErr error) string {
+	var sb strings.Builder
+	sb.WriteString(hybridLLMHeader + "\n")
+	if llmErr != nil {
+		fmt.Fprintf(&sb, "LLM transcription failed: %v\n", llmErr)
+	} else {
+		sb.WriteString(strings.TrimSpace(llmText) + "\n")
+	}
+	sb.WriteString(hybridTesseractHeader + "\n")
+	if ocrErr != nil {
+		fmt.Fprintf(&sb, "Tesseract OCR failed: %v\n", ocrErr)
+	} else {
+		sb.WriteString(strings.TrimSpace(ocr) + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunHybridOCR(t *testing.T) {
+	chunk := ChunkData{Source: "talk.mp4", VideoIndex: 1, ChunkNum: 2}
+	ocrOK := func(context.Context) (string, error) { return "func main() {}", nil }
+	ocrFailed := func(context.Context) (string, error) { return "", errors.New("tesseract failed on all 3 frames") }
+	// llmFailing stands for an LLM call that failed with err, as classified by sentLlmPrompt
+	llmFailing := func(err error) func(context.Context, string) (string, error) {
+		return func(ctx context.Context, ocrGrounding string) (string, error) {
+			return "", classifyLLMError(ctx, err)
+		}
+	}
+	llmOK := func(ctx context.Context, ocrGrounding string) (string, error) {
+		return "The slide shows func main() {}.", nil
+	}
+
+	tests := []struct {
+		name       string
+		ocr        func(context.Context) (string, error)
+		transcribe func(context.Context, string) (string, error)
+		wantErr    error
+		wantText   []string
+		wantErrors int
+	}{
+		{"both succeed", ocrOK, llmOK, nil, []string{"The slide shows", "func main() {}"}, 0},
+		{"LLM fails", ocrOK, llmFailing(status.Error(codes.InvalidArgument, "bad video")), nil, []string{"LLM transcription failed", "func main() {}"}, 1},
+		{"Tesseract fails", ocrFailed, llmOK, nil, []string{"The slide shows", "Tesseract OCR failed"}, 1},
+		{"both fail", ocrFailed, llmFailing(status.Error(codes.InvalidArgument, "bad video")), ErrLLMInvalidArgument, nil, 0},
+		{"auth fails the chunk", ocrOK, llmFailing(status.Error(codes.Unauthenticated, "API key not valid")), ErrLLMAuth, nil, 0},
+		{"budget fails the chunk", ocrOK, llmFailing(&LLMError{Kind: LLMErrorBudgetExceeded, Err: ErrDailyBudgetExceeded}), ErrDailyBudgetExceeded, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newVideoReport("talk.mp4", 1)
+			errs := &errorCollector{observer: NopObserver{}, report: report}
+			var grounding string
+			transcribe := func(ctx context.Context, ocrGrounding string) (string, error) {
+				grounding = ocrGrounding
+				return tt.transcribe(ctx, ocrGrounding)
+			}
+			text, err := runHybridOCR(quietContext(), chunk, errs, tt.ocr, transcribe)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(text, want) {
+					t.Errorf("transcript %q does not contain %q", text, want)
+				}
+			}
+			if ocrText, _ := tt.ocr(context.Background()); grounding != ocrText {
+				t.Errorf("LLM was grounded on %q, want the OCR text %q", grounding, ocrText)
+			}
+			// Failures reach the collector and the chunk's report, not only the transcript
+			if got := len(errs.errors()); got != tt.wantErrors {
+				t.Errorf("collected %d errors, want %d", got, tt.wantErrors)
+			}
+			if got := len(report.chunk(chunk.ChunkNum).Errors); got != tt.wantErrors {
+				t.Errorf("report has %d chunk errors, want %d", got, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestRunHybridOCRKeepsLLMErrorKind(t *testing.T) {
+	errs := &errorCollector{observer: NopObserver{}, report: newVideoReport("talk.mp4", 1)}
+	_, err := runHybridOCR(quietContext(), ChunkData{VideoIndex: 1, ChunkNum: 1}, errs,
+		func(context.Context) (string, error) { return "text", nil },
+		func(ctx context.Context, _ string) (string, error) {
+			return "", classifyLLMError(ctx, status.Error(codes.PermissionDenied, "denied"))
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collected := errs.errors()
+	var llmErr *LLMError
+	if len(collected) != 1 || !errors.As(collected[0], &llmErr) || llmErr.Kind != LLMErrorPermissionDenied {
+		t.Errorf("got %v, want the classified LLM error", collected)
+	}
+}
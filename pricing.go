@@ -0,0 +1,75 @@
+package videoSummaryGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ModelPrice is a model's price in US dollars per million tokens
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// PriceTable maps model names to prices. A model is priced only by an entry of its exact name,
+// or of the name priceAliases maps it to; variants such as "-lite" models are priced differently
+// from their parent and must have entries of their own.
+type PriceTable map[string]ModelPrice
+
+// DefaultPriceTable holds paid-tier list prices (prompts up to 128k/200k tokens) at the time of
+// writing. Prices change; pass a current table through SummaryConfig.Prices or --price-table.
+var DefaultPriceTable = PriceTable{
+	"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	"gemini-1.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+	"gemini-2.0-flash": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.5-flash": {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-2.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 10.00},
+}
+
+// priceAliases maps pinned versions and "-latest" names to the model whose price they share
+var priceAliases = map[string]string{
+	"gemini-1.5-flash-001":    "gemini-1.5-flash",
+	"gemini-1.5-flash-002":    "gemini-1.5-flash",
+	"gemini-1.5-flash-latest": "gemini-1.5-flash",
+	"gemini-1.5-pro-001":      "gemini-1.5-pro",
+	"gemini-1.5-pro-002":      "gemini-1.5-pro",
+	"gemini-1.5-pro-latest":   "gemini-1.5-pro",
+	"gemini-2.0-flash-001":    "gemini-2.0-flash",
+}
+
+// LoadPriceTable reads a price table from a JSON file, e.g.
+// {"gemini-2.0-flash": {"input_per_million": 0.1, "output_per_million": 0.4}}.
+func LoadPriceTable(path string) (PriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading price table %s: %w", path, err)
+	}
+	var prices PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("error parsing price table %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// lookup finds the price for model; ok is false when the model is unpriced
+func (t PriceTable) lookup(model string) (price ModelPrice, ok bool) {
+	model = strings.TrimPrefix(model, "models/")
+	if price, ok = t[model]; ok {
+		return price, true
+	}
+	if alias, isAlias := priceAliases[model]; isAlias {
+		price, ok = t[alias]
+	}
+	return price, ok
+}
+
+// cost estimates the dollar cost of a call; unpriced models cost zero
+func (t PriceTable) cost(model string, promptTokens, responseTokens int32) float64 {
+	price, ok := t.lookup(model)
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.InputPerMillion + float64(responseTokens)*price.OutputPerMillion) / 1e6
+}
@@ -0,0 +1,62 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPriceTableLookup(t *testing.T) {
+	tests := []struct {
+		model string
+		want  ModelPrice
+		ok    bool
+	}{
+		{"gemini-2.0-flash", DefaultPriceTable["gemini-2.0-flash"], true},
+		{"models/gemini-2.5-pro", DefaultPriceTable["gemini-2.5-pro"], true},
+		{"gemini-1.5-flash-002", DefaultPriceTable["gemini-1.5-flash"], true},
+		// Variants are priced differently from the model their name starts with
+		{"gemini-2.0-flash-lite", ModelPrice{}, false},
+		{"gemini-2.5-flash-lite", ModelPrice{}, false},
+		{"gemini-1.5-flash-8b", ModelPrice{}, false},
+		{"gemini-9-ultra", ModelPrice{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, ok := DefaultPriceTable.lookup(tt.model)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPriceTableCustomEntry(t *testing.T) {
+	prices := PriceTable{"gemini-2.0-flash-lite": {InputPerMillion: 0.075, OutputPerMillion: 0.30}}
+	if cost := prices.cost("gemini-2.0-flash-lite", 1_000_000, 1_000_000); cost != 0.375 {
+		t.Errorf("got cost %v, want 0.375", cost)
+	}
+	if cost := prices.cost("gemini-2.0-flash", 1_000_000, 0); cost != 0 {
+		t.Errorf("an unpriced model cost %v", cost)
+	}
+}
+
+func TestUsageSummaryFlagsUnpricedModels(t *testing.T) {
+	report := newVideoReport("/videos/talk.mp4", 1)
+	report.finish(nil, []LLMCallRecord{
+		{Model: "gemini-2.0-flash", PromptTokens: 1000, ResponseTokens: 100, ChunkNum: -1},
+		{Model: "gemini-2.0-flash-lite", PromptTokens: 1000, ResponseTokens: 100, ChunkNum: -1},
+	}, DefaultPriceTable)
+	if len(report.UnpricedModels) != 1 || report.UnpricedModels[0] != "gemini-2.0-flash-lite" {
+		t.Errorf("got unpriced models %v", report.UnpricedModels)
+	}
+
+	var out bytes.Buffer
+	printUsageSummary(&out, []*VideoReport{report})
+	summary := out.String()
+	for _, want := range []string{"talk.mp4", "$0.0001 + unpriced", "TOTAL", "Unpriced model(s) gemini-2.0-flash-lite"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary does not contain %q:\n%s", want, summary)
+		}
+	}
+}
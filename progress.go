@@ -0,0 +1,373 @@
+package videoSummaryGo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages reported in ProgressEvent.Stage, in the order a video goes through them.
+// Whisper and video transcription run side by side on each chunk.
+const (
+	ProgressStageChunking           = "chunking"
+	ProgressStageWhisper            = "whisper"
+	ProgressStageVideoTranscription = "video_transcription"
+	ProgressStageSummary            = "summary"
+)
+
+// Modes of the --progress flag
+const (
+	progressAuto  = "auto"
+	progressTTY   = "tty"
+	progressPlain = "plain"
+	progressOff   = "off"
+)
+
+// progressStages lists the stages in display order
+var progressStages = []string{ProgressStageChunking, ProgressStageWhisper, ProgressStageVideoTranscription, ProgressStageSummary}
+
+// ProgressEvent reports how far a stage of one video has got
+type ProgressEvent struct {
+	VideoPath string
+	// VideoIndex counts from 1; VideoCount is the number of videos in the run
+	VideoIndex int
+	VideoCount int
+	Stage      string
+	// Done and Total count the stage's chunks (or summary calls, for ProgressStageSummary)
+	Done  int
+	Total int
+	// Elapsed is the time since the stage started
+	Elapsed time.Duration
+	// StageETA is the time left in the stage at its throughput so far; zero until an item is done
+	StageETA time.Duration
+	// VideoETA is the time left for the whole video. Stages that have not started are estimated
+	// from earlier videos of the run, and left out when there are none.
+	VideoETA time.Duration
+	// VideoDone is set on the last event of a video, with Err holding how it failed, if it did
+	VideoDone bool
+	Err       error
+}
+
+// ProgressReporter receives progress events. Progress is called from the processing
+// goroutines, possibly concurrently, and must not block.
+type ProgressReporter interface {
+	Progress(ProgressEvent)
+}
+
+// ProgressFunc adapts a function to ProgressReporter
+type ProgressFunc func(ProgressEvent)
+
+func (f ProgressFunc) Progress(e ProgressEvent) { f(e) }
+
+// runProgress tracks the videos of a run and the per-item time of each stage, which estimates
+// the stages of later videos before they start. A nil *runProgress reports nothing.
+type runProgress struct {
+	reporter   ProgressReporter
+	videoCount int
+
+	mu sync.Mutex
+	// itemTime sums the wall time per finished item of each stage over the finished videos
+	itemTime  map[string]time.Duration
+	itemCount map[string]int
+}
+
+func newRunProgress(reporter ProgressReporter, videoCount int) *runProgress {
+	if reporter == nil {
+		return nil
+	}
+	return &runProgress{reporter: reporter, videoCount: videoCount, itemTime: map[string]time.Duration{}, itemCount: map[string]int{}}
+}
+
+// video starts tracking a video; videoIndex counts from 1
+func (r *runProgress) video(videoPath string, videoIndex int) *videoProgress {
+	if r == nil {
+		return nil
+	}
+	return &videoProgress{run: r, videoPath: videoPath, videoIndex: videoIndex, stages: map[string]*stageProgress{}}
+}
+
+// averageItem returns the average time per item of stage in earlier videos
+func (r *runProgress) averageItem(stage string) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.itemCount[stage] == 0 {
+		return 0, false
+	}
+	return r.itemTime[stage] / time.Duration(r.itemCount[stage]), true
+}
+
+type stageProgress struct {
+	done, total int
+	started     time.Time
+	finished    time.Time
+}
+
+func (s *stageProgress) eta(now time.Time) time.Duration {
+	if s.done == 0 || s.done >= s.total {
+		return 0
+	}
+	return now.Sub(s.started) / time.Duration(s.done) * time.Duration(s.total-s.done)
+}
+
+// videoProgress tracks the stages of one video. Its methods are safe for the concurrent audio
+// and video workers of a chunk, and a nil *videoProgress reports nothing.
+type videoProgress struct {
+	run        *runProgress
+	videoPath  string
+	videoIndex int
+
+	mu     sync.Mutex
+	stages map[string]*stageProgress
+	// chunks is the number of chunks, once chunking has counted them
+	chunks int
+}
+
+// start begins stage with total items
+func (v *videoProgress) start(stage string, total int) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	v.stages[stage] = &stageProgress{total: total, started: time.Now()}
+	if stage == ProgressStageChunking {
+		v.chunks = total
+	}
+	event := v.event(stage)
+	v.mu.Unlock()
+	v.run.reporter.Progress(event)
+}
+
+// done marks one more item of stage as finished
+func (v *videoProgress) done(stage string) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	s, ok := v.stages[stage]
+	if !ok {
+		v.mu.Unlock()
+		return
+	}
+	s.done++
+	if s.done == s.total {
+		s.finished = time.Now()
+	}
+	event := v.event(stage)
+	v.mu.Unlock()
+	v.run.reporter.Progress(event)
+}
+
+// finish reports the end of the video and adds its stage timings to the run's estimates
+func (v *videoProgress) finish(err error) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	last := ProgressStageChunking
+	for _, stage := range progressStages {
+		if _, ok := v.stages[stage]; ok {
+			last = stage
+		}
+	}
+	event := v.event(last)
+	event.VideoDone, event.Err, event.VideoETA, event.StageETA = true, err, 0, 0
+	v.run.mu.Lock()
+	for stage, s := range v.stages {
+		if !s.finished.IsZero() && s.total > 0 {
+			v.run.itemTime[stage] += s.finished.Sub(s.started)
+			v.run.itemCount[stage] += s.total
+		}
+	}
+	v.run.mu.Unlock()
+	v.mu.Unlock()
+	v.run.reporter.Progress(event)
+}
+
+// event builds the event for stage; the caller holds v.mu
+func (v *videoProgress) event(stage string) ProgressEvent {
+	now := time.Now()
+	s, ok := v.stages[stage]
+	if !ok {
+		// A video that failed before chunking has no stages
+		s = &stageProgress{started: now}
+	}
+	return ProgressEvent{
+		VideoPath:  v.videoPath,
+		VideoIndex: v.videoIndex,
+		VideoCount: v.run.videoCount,
+		Stage:      stage,
+		Done:       s.done,
+		Total:      s.total,
+		Elapsed:    now.Sub(s.started),
+		StageETA:   s.eta(now),
+		VideoETA:   v.videoETA(now),
+	}
+}
+
+// videoETA adds up the time left in every stage; the caller holds v.mu. Whisper and video
+// transcription overlap, so only the longer of the two counts.
+func (v *videoProgress) videoETA(now time.Time) time.Duration {
+	remaining := func(stage string) time.Duration {
+		if s, ok := v.stages[stage]; ok {
+			if s.done > 0 || s.total == 0 {
+				return s.eta(now)
+			}
+		}
+		items := v.chunks
+		if s, ok := v.stages[stage]; ok {
+			items = s.total
+		}
+		if avg, ok := v.run.averageItem(stage); ok {
+			return avg * time.Duration(items)
+		}
+		return 0
+	}
+	eta := remaining(ProgressStageChunking)
+	eta += max(remaining(ProgressStageWhisper), remaining(ProgressStageVideoTranscription))
+	// The summary stage makes at least the final call
+	if _, ok := v.stages[ProgressStageSummary]; ok {
+		eta += remaining(ProgressStageSummary)
+	} else if avg, ok := v.run.averageItem(ProgressStageSummary); ok {
+		eta += avg
+	}
+	return eta
+}
+
+// NewPlainProgress returns a reporter writing one line per event, for logs and CI
+func NewPlainProgress(w io.Writer) ProgressReporter {
+	return &plainProgress{w: w}
+}
+
+type plainProgress struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (p *plainProgress) Progress(e ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.w, formatProgressLine(e))
+}
+
+// NewTerminalProgress returns a reporter that redraws a block of progress bars, one per stage of
+// the current video, on a terminal. Finished videos are left as a single summary line.
+// The reporter is also an io.Writer: log output written through it is printed above the block
+// instead of into it.
+func NewTerminalProgress(w io.Writer) ProgressReporter {
+	return newTerminalProgress(w)
+}
+
+func newTerminalProgress(w io.Writer) *terminalProgress {
+	return &terminalProgress{w: w, latest: map[string]ProgressEvent{}}
+}
+
+type terminalProgress struct {
+	mu     sync.Mutex
+	w      io.Writer
+	latest map[string]ProgressEvent
+	// current is the last event of the video on screen, for the block's header
+	current ProgressEvent
+	// drawn is the number of lines of the block on screen
+	drawn int
+}
+
+const progressBarWidth = 24
+
+func (t *terminalProgress) Progress(e ProgressEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+	t.clear(&sb)
+	if e.VideoDone {
+		sb.WriteString(formatProgressLine(e) + "\n")
+		t.latest = map[string]ProgressEvent{}
+	} else {
+		t.latest[e.Stage] = e
+		t.current = e
+		t.draw(&sb)
+	}
+	io.WriteString(t.w, sb.String())
+}
+
+// Write prints p, which should be whole lines such as log records, above the progress block
+func (t *terminalProgress) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+	t.clear(&sb)
+	sb.Write(p)
+	t.draw(&sb)
+	if _, err := io.WriteString(t.w, sb.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// clear moves to the start of the block on screen and erases it; the caller holds t.mu
+func (t *terminalProgress) clear(sb *strings.Builder) {
+	if t.drawn > 0 {
+		fmt.Fprintf(sb, "\x1b[%dF\x1b[J", t.drawn)
+		t.drawn = 0
+	}
+}
+
+// draw renders the block for the current video, if there is one; the caller holds t.mu
+func (t *terminalProgress) draw(sb *strings.Builder) {
+	if len(t.latest) == 0 {
+		return
+	}
+	e := t.current
+	fmt.Fprintf(sb, "[%d/%d] %s  ETA %s\n", e.VideoIndex, e.VideoCount, filepath.Base(e.VideoPath), formatETA(e.VideoETA))
+	lines := 1
+	for _, stage := range progressStages {
+		s, ok := t.latest[stage]
+		if !ok {
+			continue
+		}
+		filled := 0
+		if s.Total > 0 {
+			filled = progressBarWidth * s.Done / s.Total
+		}
+		bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled)
+		fmt.Fprintf(sb, "  %-19s [%s] %d/%d  ETA %s\n", stage, bar, s.Done, s.Total, formatETA(s.StageETA))
+		lines++
+	}
+	t.drawn = lines
+}
+
+// formatProgressLine renders an event as a single line
+func formatProgressLine(e ProgressEvent) string {
+	name := filepath.Base(e.VideoPath)
+	if e.VideoDone {
+		status := "done"
+		if e.Err != nil {
+			status = "failed: " + e.Err.Error()
+		}
+		return fmt.Sprintf("[%d/%d] %s %s", e.VideoIndex, e.VideoCount, name, status)
+	}
+	percent := 0
+	if e.Total > 0 {
+		percent = 100 * e.Done / e.Total
+	}
+	return fmt.Sprintf("[%d/%d] %s %s %d/%d (%d%%) elapsed %s, stage ETA %s, video ETA %s", e.VideoIndex, e.VideoCount, name, e.Stage,
+		e.Done, e.Total, percent, e.Elapsed.Round(time.Second), formatETA(e.StageETA), formatETA(e.VideoETA))
+}
+
+// isTerminal reports whether f is a character device, i.e. not redirected to a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatETA(d time.Duration) string {
+	if d <= 0 {
+		return "--"
+	}
+	return d.Round(time.Second).String()
+}
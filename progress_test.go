@@ -0,0 +1,137 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageETA(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		done, total int
+		elapsed     time.Duration
+		want        time.Duration
+	}{
+		{"nothing done yet", 0, 4, 10 * time.Second, 0},
+		{"at throughput so far", 2, 5, 10 * time.Second, 15 * time.Second},
+		{"finished", 3, 3, 10 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &stageProgress{done: tt.done, total: tt.total, started: now.Add(-tt.elapsed)}
+			if got := s.eta(now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVideoETA(t *testing.T) {
+	now := time.Now()
+	run := newRunProgress(ProgressFunc(func(ProgressEvent) {}), 2)
+	// An earlier video took 4s per whisper chunk, 6s per video chunk and 20s for its summary
+	run.itemTime = map[string]time.Duration{ProgressStageWhisper: 8 * time.Second, ProgressStageVideoTranscription: 12 * time.Second, ProgressStageSummary: 20 * time.Second}
+	run.itemCount = map[string]int{ProgressStageWhisper: 2, ProgressStageVideoTranscription: 2, ProgressStageSummary: 1}
+
+	v := run.video("b.mp4", 2)
+	v.chunks = 3
+	v.stages[ProgressStageChunking] = &stageProgress{done: 3, total: 3, started: now.Add(-time.Minute)}
+	// Whisper is measured from this video: 1 of 3 chunks in 10s leaves 20s. Video transcription
+	// has not finished a chunk, so the earlier 6s per chunk estimates its 18s; the longer of the
+	// two overlapping stages counts. The summary has not started: one call of 20s.
+	v.stages[ProgressStageWhisper] = &stageProgress{done: 1, total: 3, started: now.Add(-10 * time.Second)}
+	v.stages[ProgressStageVideoTranscription] = &stageProgress{total: 3, started: now.Add(-10 * time.Second)}
+	if got, want := v.videoETA(now), 40*time.Second; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Without earlier videos, stages that have not made progress are left out
+	first := newRunProgress(ProgressFunc(func(ProgressEvent) {}), 1).video("a.mp4", 1)
+	first.chunks = 3
+	first.stages[ProgressStageWhisper] = &stageProgress{done: 1, total: 3, started: now.Add(-10 * time.Second)}
+	if got, want := first.videoETA(now), 20*time.Second; got != want {
+		t.Errorf("first video: got %v, want %v", got, want)
+	}
+}
+
+func TestRunProgressLearnsFromFinishedVideos(t *testing.T) {
+	var events []ProgressEvent
+	run := newRunProgress(ProgressFunc(func(e ProgressEvent) { events = append(events, e) }), 2)
+	v := run.video("a.mp4", 1)
+	v.start(ProgressStageWhisper, 2)
+	v.done(ProgressStageWhisper)
+	v.done(ProgressStageWhisper)
+	v.finish(nil)
+	if avg, ok := run.averageItem(ProgressStageWhisper); !ok || avg < 0 {
+		t.Errorf("got average %v, %v after a finished stage", avg, ok)
+	}
+	last := events[len(events)-1]
+	if !last.VideoDone || last.Stage != ProgressStageWhisper || last.VideoETA != 0 {
+		t.Errorf("got final event %+v", last)
+	}
+	if n := len(events); n != 4 {
+		t.Errorf("got %d events, want 4", n)
+	}
+}
+
+func TestPlainProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPlainProgress(&out)
+	p.Progress(ProgressEvent{VideoPath: "/v/talk.mp4", VideoIndex: 1, VideoCount: 2, Stage: ProgressStageWhisper, Done: 1, Total: 4,
+		Elapsed: 12 * time.Second, StageETA: 36 * time.Second, VideoETA: 90 * time.Second})
+	p.Progress(ProgressEvent{VideoPath: "/v/talk.mp4", VideoIndex: 1, VideoCount: 2, Stage: ProgressStageWhisper})
+	p.Progress(ProgressEvent{VideoPath: "/v/talk.mp4", VideoIndex: 1, VideoCount: 2, VideoDone: true})
+	p.Progress(ProgressEvent{VideoPath: "/v/demo.mp4", VideoIndex: 2, VideoCount: 2, VideoDone: true, Err: errors.New("no audio stream")})
+	want := strings.Join([]string{
+		"[1/2] talk.mp4 whisper 1/4 (25%) elapsed 12s, stage ETA 36s, video ETA 1m30s",
+		"[1/2] talk.mp4 whisper 0/0 (0%) elapsed 0s, stage ETA --, video ETA --",
+		"[1/2] talk.mp4 done",
+		"[2/2] demo.mp4 failed: no audio stream",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestTerminalProgressLogsAboveBlock(t *testing.T) {
+	var out bytes.Buffer
+	p := newTerminalProgress(&out)
+	logger := slog.New(slog.NewTextHandler(p, &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}}))
+
+	event := ProgressEvent{VideoPath: "/v/talk.mp4", VideoIndex: 1, VideoCount: 1, Stage: ProgressStageWhisper, Done: 1, Total: 2}
+	p.Progress(event)
+	block := "[1/1] talk.mp4  ETA --\n  whisper             [############------------] 1/2  ETA --\n"
+	if out.String() != block {
+		t.Fatalf("got %q, want %q", out.String(), block)
+	}
+
+	out.Reset()
+	logger.Warn("Chunk failed")
+	// The block is erased, the log line printed in its place and the block drawn again below it
+	if want := "\x1b[2F\x1b[J" + "level=WARN msg=\"Chunk failed\"\n" + block; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	p.Progress(ProgressEvent{VideoPath: "/v/talk.mp4", VideoIndex: 1, VideoCount: 1, Stage: ProgressStageWhisper, VideoDone: true})
+	if want := "\x1b[2F\x1b[J[1/1] talk.mp4 done\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	// With no block on screen, log lines are written as they are
+	out.Reset()
+	logger.Error("Run failed")
+	if want := "level=ERROR msg=\"Run failed\"\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,114 @@
+package videoSummaryGo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultSummaryLanguage is used when SummaryConfig.SummaryLanguage is empty
+const defaultSummaryLanguage = "English"
+
+const summaryPromptTask = `Here is a raw transcription of a video. Your task is to refine it into a well-structured, human-like summary with explanations while keeping all the original details. Analyze the lecture provided in the audio transcription and video text. Identify the main topic, key arguments, supporting evidence, and any examples used, highlighting the connections between different ideas. Use information from both the audio transcription and video text to create a comprehensive explanation, also use timestamp to help us correlate with the audio transcript:`
+
+const videoOnlyPromptTask = `Here is a raw transcription of the text shown in a video that has no spoken audio (for example a silent screencast or slide deck). Your task is to refine it into a well-structured, human-like summary with explanations while keeping all the original details. Identify the main topic, key arguments, supporting evidence, and any examples used, highlighting the connections between different ideas, and use the chunk order to follow how the content progresses:`
+
+const chunkSummariesPromptTask = `Here are detailed summaries of consecutive chunks of one video, in order. Your task is to combine them into a single well-structured, human-like summary of the whole video with explanations while keeping all the original details. Identify the main topic, key arguments, supporting evidence, and any examples used, highlighting the connections between ideas across chunks, and remove repetition where chunks overlap:`
+
+const chunkSummaryPromptTask = `Here is the raw transcription of one chunk of a longer video. Summarize this chunk in detail, keeping every fact, argument, example, name and number, so that the summaries of all chunks can later be combined into one summary of the whole video. Do not add an introduction or a conclusion:`
+
+const summaryPromptClosing = `Please rewrite it clearly with explanations where needed, ensuring it's easy to read and understand.`
+
+// summaryPromptInput is everything the final summary prompt is built from
+type summaryPromptInput struct {
+	InputFromUser   string
+	AudioTranscript string
+	VideoTranscript string
+	VideoOnly       bool
+
+	// SourceLanguage lists the languages spoken in the recording, e.g. "de" or "en, fr"
+	SourceLanguage string
+	// Translated is set when whisper already translated the audio transcript to English
+	Translated      bool
+	SummaryLanguage string
+	// Diarized is set when the audio transcription is labelled by speaker
+	Diarized bool
+	// SpeakerTurns is set when the audio transcription only marks changes of speaker (tinydiarize)
+	SpeakerTurns bool
+	// HybridOCR is set when the video text has both an LLM and a Tesseract transcript per chunk
+	HybridOCR bool
+	// ChunkSummaries, when set, replaces the raw transcriptions with per-chunk summaries
+	ChunkSummaries string
+}
+
+// buildSummaryPrompt assembles the final summary prompt for one video
+func buildSummaryPrompt(in summaryPromptInput) string {
+	var sb strings.Builder
+	if in.InputFromUser != "" {
+		fmt.Fprintf(&sb, "Context from user about this video: %s\n", in.InputFromUser)
+	}
+
+	switch {
+	case in.ChunkSummaries != "":
+		sb.WriteString(chunkSummariesPromptTask)
+	case in.VideoOnly:
+		sb.WriteString(videoOnlyPromptTask)
+	default:
+		sb.WriteString(summaryPromptTask)
+	}
+	sb.WriteString("\n\n")
+	writePromptContext(&sb, in)
+
+	if in.ChunkSummaries != "" {
+		fmt.Fprintf(&sb, "    --- CHUNK SUMMARIES ---\n    %s\n\n", in.ChunkSummaries)
+	} else {
+		writeRawTranscripts(&sb, in)
+	}
+	sb.WriteString("    " + summaryPromptClosing)
+	return sb.String()
+}
+
+// buildChunkSummaryPrompt assembles the prompt summarizing one chunk; in carries that chunk's transcripts
+func buildChunkSummaryPrompt(in summaryPromptInput, chunkNum int, offset time.Duration) string {
+	var sb strings.Builder
+	if in.InputFromUser != "" {
+		fmt.Fprintf(&sb, "Context from user about this video: %s\n", in.InputFromUser)
+	}
+	sb.WriteString(chunkSummaryPromptTask)
+	sb.WriteString("\n\n")
+	fmt.Fprintf(&sb, "This is chunk %d, starting %v into the video.\n", chunkNum, offset)
+	writePromptContext(&sb, in)
+	writeRawTranscripts(&sb, in)
+	return sb.String()
+}
+
+// writePromptContext writes the language and speaker notes shared by the summary prompts
+func writePromptContext(sb *strings.Builder, in summaryPromptInput) {
+	if !in.VideoOnly && in.SourceLanguage != "" {
+		if in.Translated {
+			fmt.Fprintf(sb, "Source language of the recording: %s (the audio transcription below has already been translated to English).\n", in.SourceLanguage)
+		} else {
+			fmt.Fprintf(sb, "Source language of the recording: %s.\n", in.SourceLanguage)
+		}
+	}
+	if !in.VideoOnly && in.Diarized {
+		sb.WriteString("The audio transcription is labelled by speaker. Attribute statements, decisions and action items to the speaker who made them.\n")
+	} else if !in.VideoOnly && in.SpeakerTurns {
+		fmt.Fprintf(sb, "In the audio transcription, %q marks where a different person starts speaking; it does not tell who is speaking.\n", speakerChangeLabel)
+	}
+	if in.HybridOCR {
+		sb.WriteString("Each chunk of the video text has an LLM transcription and a literal Tesseract OCR transcription, labelled by source. Prefer the LLM transcription, and use the OCR to check code, formulas and numbers where they disagree.\n")
+	}
+	summaryLanguage := in.SummaryLanguage
+	if summaryLanguage == "" {
+		summaryLanguage = defaultSummaryLanguage
+	}
+	fmt.Fprintf(sb, "Write the summary in: %s.\n\n", summaryLanguage)
+}
+
+func writeRawTranscripts(sb *strings.Builder, in summaryPromptInput) {
+	if !in.VideoOnly {
+		fmt.Fprintf(sb, "    --- RAW TRANSCRIPTION of Audio ---\n    %s\n\n", in.AudioTranscript)
+	}
+	fmt.Fprintf(sb, "    --- RAW TRANSCRIPTION of Video Text ---\n    %s\n\n", in.VideoTranscript)
+}
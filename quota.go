@@ -0,0 +1,356 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"golang.org/x/time/rate"
+)
+
+// estimatedTokensPerVideoSecond approximates what one second of video costs across the run:
+// Gemini bills ~258 tokens per sampled frame at 1 fps, plus the transcripts fed to the summary.
+const estimatedTokensPerVideoSecond = 300
+
+const (
+	// quotaLockTimeout bounds the wait for another process to finish updating the usage file
+	quotaLockTimeout = 10 * time.Second
+	// quotaLockStale is when a lock file is considered left behind by a crashed process
+	quotaLockStale = 30 * time.Second
+	// quotaSaveInterval is how often recorded usage is merged into the usage file; Flush saves
+	// the rest when a run ends
+	quotaSaveInterval = 15 * time.Second
+)
+
+// QuotaConfig limits how fast and how much a run may call Gemini. Zero fields are unlimited.
+type QuotaConfig struct {
+	// RequestsPerMinute bounds GenerateContent calls and file uploads together
+	RequestsPerMinute int
+	// TokensPerMinute bounds prompt tokens sent, as measured by CountTokens before each call
+	TokensPerMinute int
+	// DailyTokenBudget bounds total tokens (prompt and response) used per calendar day
+	DailyTokenBudget int64
+	// StatePath is where today's usage is persisted so the budget holds across runs;
+	// defaultQuotaStatePath is used when empty.
+	StatePath string
+}
+
+// ErrDailyBudgetExceeded is returned when a call would take the day's usage over DailyTokenBudget
+var ErrDailyBudgetExceeded = errors.New("daily token budget exceeded")
+
+// dailyUsage is the persisted form of a QuotaLimiter's usage
+type dailyUsage struct {
+	Date     string `json:"date"`
+	Tokens   int64  `json:"tokens"`
+	Requests int64  `json:"requests"`
+}
+
+// QuotaLimiter is a token-bucket limiter for requests and tokens per minute plus a daily token
+// budget. One limiter may be shared by several runs against the same API key (SummaryConfig.QuotaLimiter).
+// Processes using the same state file add up their usage there, every quotaSaveInterval and on
+// Flush, so each sees the others' usage with that much delay. A nil *QuotaLimiter does not
+// limit anything.
+type QuotaLimiter struct {
+	requests *rate.Limiter
+	tokens   *rate.Limiter
+	budget   int64
+
+	mu        sync.Mutex
+	statePath string
+	// usage is today's total as of the last save, plus unsaved
+	usage dailyUsage
+	// unsaved is the usage recorded since the last save, still to be added to the file
+	unsaved dailyUsage
+	// reserved is the tokens held by calls in flight
+	reserved int64
+	// lastSave is when usage was last merged into the file
+	lastSave time.Time
+}
+
+// defaultQuotaStatePath returns the usage file under the user's cache directory
+func defaultQuotaStatePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating cache directory for quota state: %w", err)
+	}
+	return filepath.Join(cacheDir, "videoSummaryGo", "quota.json"), nil
+}
+
+// NewQuotaLimiter builds a limiter for cfg, loading today's usage when a daily budget is set.
+// It returns nil when cfg sets no limits.
+func NewQuotaLimiter(cfg QuotaConfig) (*QuotaLimiter, error) {
+	if cfg.RequestsPerMinute <= 0 && cfg.TokensPerMinute <= 0 && cfg.DailyTokenBudget <= 0 {
+		return nil, nil
+	}
+	q := &QuotaLimiter{budget: cfg.DailyTokenBudget}
+	if cfg.RequestsPerMinute > 0 {
+		q.requests = rate.NewLimiter(rate.Limit(float64(cfg.RequestsPerMinute)/60), cfg.RequestsPerMinute)
+	}
+	if cfg.TokensPerMinute > 0 {
+		q.tokens = rate.NewLimiter(rate.Limit(float64(cfg.TokensPerMinute)/60), cfg.TokensPerMinute)
+	}
+	if q.budget > 0 {
+		q.statePath = cfg.StatePath
+		if q.statePath == "" {
+			path, err := defaultQuotaStatePath()
+			if err != nil {
+				return nil, err
+			}
+			q.statePath = path
+		}
+		if err := q.load(); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func today() string {
+	return time.Now().Format(time.DateOnly)
+}
+
+func (q *QuotaLimiter) load() error {
+	q.usage = dailyUsage{Date: today()}
+	q.unsaved = dailyUsage{Date: q.usage.Date}
+	saved, err := readUsage(q.statePath)
+	if err != nil {
+		return err
+	}
+	if saved.Date == q.usage.Date {
+		q.usage = saved
+	}
+	return nil
+}
+
+// readUsage reads a usage file, returning the zero usage when there is none
+func readUsage(path string) (dailyUsage, error) {
+	var saved dailyUsage
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return saved, nil
+	}
+	if err != nil {
+		return saved, fmt.Errorf("error reading quota state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return saved, fmt.Errorf("error parsing quota state %s: %w", path, err)
+	}
+	return saved, nil
+}
+
+// save adds the unsaved usage to the usage file and takes the result, which includes what
+// other processes recorded meanwhile, as today's total; the caller holds q.mu. Usage that
+// could not be saved is kept for the next save.
+func (q *QuotaLimiter) save(ctx context.Context) {
+	q.lastSave = time.Now()
+	if err := q.merge(); err != nil {
+		logFrom(ctx).Warn("Failed to save quota state", "path", q.statePath, "error", err)
+	}
+}
+
+func (q *QuotaLimiter) merge() error {
+	if err := os.MkdirAll(filepath.Dir(q.statePath), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(q.statePath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	saved, err := readUsage(q.statePath)
+	if err != nil {
+		return err
+	}
+	if saved.Date != q.unsaved.Date {
+		saved = dailyUsage{Date: q.unsaved.Date}
+	}
+	saved.Tokens += q.unsaved.Tokens
+	saved.Requests += q.unsaved.Requests
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	// Through a temporary file, so a crash never leaves the file half written
+	tmp := q.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.statePath); err != nil {
+		return err
+	}
+	q.usage = saved
+	q.unsaved = dailyUsage{Date: saved.Date}
+	return nil
+}
+
+// lockFile takes an exclusive lock between processes by creating path, waiting up to
+// quotaLockTimeout for the holder. A lock older than quotaLockStale is taken over.
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(quotaLockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error creating lock %s: %w", path, err)
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > quotaLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// rollover resets usage at midnight; the caller holds q.mu
+func (q *QuotaLimiter) rollover() {
+	if d := today(); q.usage.Date != d {
+		q.usage = dailyUsage{Date: d}
+		q.unsaved = dailyUsage{Date: d}
+	}
+}
+
+// countsTokens reports whether calls need a CountTokens pre-flight
+func (q *QuotaLimiter) countsTokens() bool {
+	return q != nil && (q.tokens != nil || q.budget > 0)
+}
+
+// WaitRequest blocks until another request may be sent
+func (q *QuotaLimiter) WaitRequest(ctx context.Context) error {
+	if q == nil || q.requests == nil {
+		return nil
+	}
+	return q.requests.Wait(ctx)
+}
+
+// WaitTokens blocks until n more prompt tokens may be sent. Requests larger than a minute's
+// allowance wait for the whole bucket instead of failing.
+func (q *QuotaLimiter) WaitTokens(ctx context.Context, n int) error {
+	if q == nil || q.tokens == nil || n <= 0 {
+		return nil
+	}
+	return q.tokens.WaitN(ctx, min(n, q.tokens.Burst()))
+}
+
+// Remaining returns the tokens left in today's budget, less those reserved by calls in flight;
+// ok is false when there is no budget
+func (q *QuotaLimiter) Remaining() (remaining int64, ok bool) {
+	if q == nil || q.budget <= 0 {
+		return 0, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	return q.remaining(), true
+}
+
+// remaining is Remaining for a caller holding q.mu
+func (q *QuotaLimiter) remaining() int64 {
+	return max(q.budget-q.usage.Tokens-q.reserved, 0)
+}
+
+// Reserve holds n tokens of today's budget for a call about to be sent, so concurrent calls
+// cannot together overshoot it. The call hands them back through Record or Release.
+func (q *QuotaLimiter) Reserve(n int64) error {
+	if q == nil || q.budget <= 0 || n <= 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	if remaining := q.remaining(); n > remaining {
+		return fmt.Errorf("%w: call needs ~%d tokens, %d of %d left today", ErrDailyBudgetExceeded, n, remaining, q.budget)
+	}
+	q.reserved += n
+	return nil
+}
+
+// Release hands back reserved tokens that a call did not use, e.g. because it failed
+func (q *QuotaLimiter) Release(reserved int64) {
+	if q == nil || q.budget <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reserved = max(q.reserved-reserved, 0)
+}
+
+// Record adds a finished call's token usage to today's total, in place of the tokens it reserved.
+// The usage file is updated at most every quotaSaveInterval.
+func (q *QuotaLimiter) Record(ctx context.Context, tokens, reserved int64) {
+	if q == nil || q.budget <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	q.reserved = max(q.reserved-reserved, 0)
+	q.usage.Tokens += tokens
+	q.usage.Requests++
+	q.unsaved.Tokens += tokens
+	q.unsaved.Requests++
+	if time.Since(q.lastSave) >= quotaSaveInterval {
+		q.save(ctx)
+	}
+}
+
+// Flush saves the usage recorded since the last save, so other processes see it
+func (q *QuotaLimiter) Flush(ctx context.Context) {
+	if q == nil || q.budget <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.unsaved.Requests > 0 {
+		q.save(ctx)
+	}
+}
+
+// countPromptTokens runs the CountTokens pre-flight for prompt
+func countPromptTokens(ctx context.Context, model *genai.GenerativeModel, prompt []genai.Part) (int64, error) {
+	resp, err := model.CountTokens(ctx, prompt...)
+	if err != nil {
+		return 0, err
+	}
+	return int64(resp.TotalTokens), nil
+}
+
+// estimateRunTokens approximates the tokens needed to summarize videoPaths from their durations
+func estimateRunTokens(ctx context.Context, videoPaths []string) int64 {
+	var seconds float64
+	for _, path := range videoPaths {
+		duration, err := probeDuration(ctx, path)
+		if err != nil {
+			logFrom(ctx).Warn("Could not estimate tokens", logKeyVideo, path, "error", err)
+			continue
+		}
+		seconds += duration
+	}
+	return int64(seconds * estimatedTokensPerVideoSecond)
+}
+
+// warnIfRunExceedsBudget prints a warning when a run is likely to use more than today's remaining
+// budget. The run still starts; calls fail with ErrDailyBudgetExceeded once the budget is spent.
+func (q *QuotaLimiter) warnIfRunExceedsBudget(ctx context.Context, videoPaths []string) {
+	remaining, ok := q.Remaining()
+	if !ok {
+		return
+	}
+	estimate := estimateRunTokens(ctx, videoPaths)
+	if estimate > remaining {
+		logFrom(ctx).Warn("This run is estimated to need more tokens than remain in the daily budget; it will stop once the budget is spent", "estimated_tokens", estimate, "remaining_tokens", remaining, "daily_budget", q.budget)
+	} else {
+		logFrom(ctx).Info("Estimated token usage for this run", "estimated_tokens", estimate, "remaining_tokens", remaining)
+	}
+}
@@ -0,0 +1,137 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQuotaReserveConcurrent(t *testing.T) {
+	q, err := NewQuotaLimiter(QuotaConfig{DailyTokenBudget: 1000, StatePath: filepath.Join(t.TempDir(), "quota.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var granted atomic.Int64
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := q.Reserve(100)
+			switch {
+			case err == nil:
+				granted.Add(1)
+			case !errors.Is(err, ErrDailyBudgetExceeded):
+				t.Errorf("Reserve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := granted.Load(); n != 10 {
+		t.Fatalf("%d reservations of 100 tokens fit a budget of 1000", n)
+	}
+
+	// Usage replaces the reservation; released tokens become available again
+	q.Record(context.Background(), 50, 100)
+	q.Release(100)
+	if remaining, _ := q.Remaining(); remaining != 1000-50-8*100 {
+		t.Errorf("remaining is %d, want %d", remaining, 1000-50-8*100)
+	}
+}
+
+func TestQuotaSharedStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	cfg := QuotaConfig{DailyTokenBudget: 1_000_000, StatePath: path}
+	// Two limiters on one file stand for two processes
+	a, err := NewQuotaLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewQuotaLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, q := range []*QuotaLimiter{a, b} {
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				q.Record(context.Background(), 10, 0)
+			}()
+		}
+	}
+	wg.Wait()
+	a.Flush(context.Background())
+	b.Flush(context.Background())
+
+	saved, err := readUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Tokens != 400 || saved.Requests != 40 {
+		t.Errorf("file holds %d tokens in %d requests, want 400 in 40", saved.Tokens, saved.Requests)
+	}
+	// Each limiter sees the other's usage as of its last save
+	c, err := NewQuotaLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining, _ := c.Remaining(); remaining != 1_000_000-400 {
+		t.Errorf("a new limiter has %d tokens left, want %d", remaining, 1_000_000-400)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Errorf("left behind %v", matches)
+	}
+}
+
+func TestQuotaSavesAreDebounced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	q, err := NewQuotaLimiter(QuotaConfig{DailyTokenBudget: 1000, StatePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// The first call is saved at once, the next ones wait for quotaSaveInterval or Flush
+	for range 3 {
+		q.Record(ctx, 10, 0)
+	}
+	if saved, _ := readUsage(path); saved.Tokens != 10 || saved.Requests != 1 {
+		t.Errorf("file holds %+v before Flush, want only the first call", saved)
+	}
+	if remaining, _ := q.Remaining(); remaining != 1000-30 {
+		t.Errorf("remaining is %d, want unsaved usage counted too", remaining)
+	}
+	q.Flush(ctx)
+	if saved, _ := readUsage(path); saved.Tokens != 30 || saved.Requests != 3 {
+		t.Errorf("file holds %+v after Flush, want 30 tokens in 3 requests", saved)
+	}
+}
+
+func TestQuotaSaveErrorsUseContextLogger(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "state")
+	q, err := NewQuotaLimiter(QuotaConfig{DailyTokenBudget: 1000, StatePath: filepath.Join(parent, "quota.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A regular file where the state directory should be makes saving fail
+	if err := os.WriteFile(parent, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	ctx := withLogger(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)))
+	q.Record(ctx, 10, 0)
+	if !bytes.Contains(logs.Bytes(), []byte("Failed to save quota state")) {
+		t.Errorf("save error not logged through the context's logger: %q", logs.String())
+	}
+	// Unsaved usage still counts against the budget
+	if remaining, _ := q.Remaining(); remaining != 990 {
+		t.Errorf("remaining is %d, want 990", remaining)
+	}
+}
@@ -0,0 +1,249 @@
+package videoSummaryGo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Stages timed in VideoReport.StageSeconds
+const (
+	stageChunking      = "chunking"
+	stageTranscription = "transcription"
+	stageChunkSummary  = "chunk_summary"
+	stageFinalSummary  = "final_summary"
+)
+
+// VideoReport is written as <base>_report.json next to a video's other output files
+type VideoReport struct {
+	VideoPath  string          `json:"video_path"`
+	VideoIndex int             `json:"video_index"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Usage      UsageTotals     `json:"usage"`
+	Chunks     []*ChunkReport  `json:"chunks"`
+	LLMCalls   []LLMCallRecord `json:"llm_calls"`
+	Error      string          `json:"error,omitempty"`
+	// StageSeconds is the wall time spent in each stage of the video
+	StageSeconds map[string]float64 `json:"stage_seconds"`
+	// UnpricedModels lists models used in this video that had no entry in the price table
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+	// SourceSize, SourceModTime and SourceSHA256 identify the video a successful run summarized,
+	// so later folder runs can skip it
+	SourceSize    int64     `json:"source_size,omitempty"`
+	SourceModTime time.Time `json:"source_mod_time,omitzero"`
+	SourceSHA256  string    `json:"source_sha256,omitempty"`
+
+	mu sync.Mutex
+}
+
+// UsageTotals adds up the resources a video (or a whole run) used
+type UsageTotals struct {
+	LLMCalls          int     `json:"llm_calls"`
+	PromptTokens      int64   `json:"prompt_tokens"`
+	ResponseTokens    int64   `json:"response_tokens"`
+	UploadBytes       int64   `json:"upload_bytes"`
+	WhisperCPUSeconds float64 `json:"whisper_cpu_seconds"`
+	TesseractFrames   int     `json:"tesseract_frames"`
+	WallSeconds       float64 `json:"wall_seconds"`
+	EstimatedCostUSD  float64 `json:"estimated_cost_usd"`
+}
+
+func (u *UsageTotals) add(o UsageTotals) {
+	u.LLMCalls += o.LLMCalls
+	u.PromptTokens += o.PromptTokens
+	u.ResponseTokens += o.ResponseTokens
+	u.UploadBytes += o.UploadBytes
+	u.WhisperCPUSeconds += o.WhisperCPUSeconds
+	u.TesseractFrames += o.TesseractFrames
+	u.WallSeconds += o.WallSeconds
+	u.EstimatedCostUSD += o.EstimatedCostUSD
+}
+
+// ChunkReport is the per-chunk part of a VideoReport. Its methods are safe for the concurrent
+// audio and video workers of a chunk, and a nil *ChunkReport discards everything.
+type ChunkReport struct {
+	ChunkNum int `json:"chunk_num"`
+	// AudioSeconds and VideoSeconds are the wall time of the chunk's audio and video transcription
+	AudioSeconds      float64 `json:"audio_seconds"`
+	VideoSeconds      float64 `json:"video_seconds"`
+	WhisperCPUSeconds float64 `json:"whisper_cpu_seconds"`
+	UploadBytes       int64   `json:"upload_bytes"`
+	TesseractFrames   int     `json:"tesseract_frames"`
+	LLMCalls          int     `json:"llm_calls"`
+	PromptTokens      int64   `json:"prompt_tokens"`
+	ResponseTokens    int64   `json:"response_tokens"`
+	EstimatedCostUSD  float64 `json:"estimated_cost_usd"`
+	// Errors are the chunk's failures; its transcripts then hold a placeholder for the failed part
+	Errors []string `json:"errors,omitempty"`
+
+	mu sync.Mutex
+}
+
+func (c *ChunkReport) addError(err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Errors = append(c.Errors, err.Error())
+}
+
+func (c *ChunkReport) addUpload(bytes int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.UploadBytes += bytes
+}
+
+func (c *ChunkReport) addTesseractFrames(n int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.TesseractFrames += n
+}
+
+func (c *ChunkReport) addAudio(wall, whisperCPU time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AudioSeconds += wall.Seconds()
+	c.WhisperCPUSeconds += whisperCPU.Seconds()
+}
+
+func (c *ChunkReport) addVideo(wall time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.VideoSeconds += wall.Seconds()
+}
+
+func newVideoReport(videoPath string, videoIndex int) *VideoReport {
+	return &VideoReport{VideoPath: videoPath, VideoIndex: videoIndex, StartedAt: time.Now(), StageSeconds: map[string]float64{}}
+}
+
+// reportPath returns the report file for videoPath
+func reportPath(videoPath string) string {
+	baseName := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	return filepath.Join(filepath.Dir(videoPath), baseName+"_report.json")
+}
+
+// chunk returns the report for chunkNum, adding it on first use
+func (r *VideoReport) chunk(chunkNum int) *ChunkReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.Chunks {
+		if c.ChunkNum == chunkNum {
+			return c
+		}
+	}
+	c := &ChunkReport{ChunkNum: chunkNum}
+	r.Chunks = append(r.Chunks, c)
+	return c
+}
+
+// timeStage adds the wall time since start to stage
+func (r *VideoReport) timeStage(stage string, start time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.StageSeconds[stage] += time.Since(start).Seconds()
+}
+
+// finish records how the video ended, prices its LLM calls and totals its usage
+func (r *VideoReport) finish(err error, calls []LLMCallRecord, prices PriceTable) {
+	r.FinishedAt = time.Now()
+	r.LLMCalls = calls
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	var total UsageTotals
+	for _, call := range calls {
+		cost := prices.cost(call.Model, call.PromptTokens, call.ResponseTokens)
+		if _, ok := prices.lookup(call.Model); !ok && call.Model != "" && !slices.Contains(r.UnpricedModels, call.Model) {
+			r.UnpricedModels = append(r.UnpricedModels, call.Model)
+		}
+		total.LLMCalls++
+		total.PromptTokens += int64(call.PromptTokens)
+		total.ResponseTokens += int64(call.ResponseTokens)
+		total.EstimatedCostUSD += cost
+		if call.ChunkNum >= 0 {
+			c := r.chunk(call.ChunkNum)
+			c.LLMCalls++
+			c.PromptTokens += int64(call.PromptTokens)
+			c.ResponseTokens += int64(call.ResponseTokens)
+			c.EstimatedCostUSD += cost
+		}
+	}
+	for _, c := range r.Chunks {
+		total.UploadBytes += c.UploadBytes
+		total.WhisperCPUSeconds += c.WhisperCPUSeconds
+		total.TesseractFrames += c.TesseractFrames
+	}
+	total.WallSeconds = r.FinishedAt.Sub(r.StartedAt).Seconds()
+	r.Usage = total
+	slices.SortFunc(r.Chunks, func(a, b *ChunkReport) int { return a.ChunkNum - b.ChunkNum })
+}
+
+// write saves the report as <base>_report.json
+func (r *VideoReport) write() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report for video %s: %w", r.VideoPath, err)
+	}
+	path := reportPath(r.VideoPath)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing report %s: %w", path, err)
+	}
+	return nil
+}
+
+// printUsageSummary prints one row per video and a total
+func printUsageSummary(w io.Writer, reports []*VideoReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Video\tLLM calls\tPrompt tokens\tOutput tokens\tUpload MB\tWhisper CPU\tOCR frames\tWall time\tEst. cost\t")
+	row := func(name string, u UsageTotals, unpriced bool) {
+		cost := fmt.Sprintf("$%.4f", u.EstimatedCostUSD)
+		if unpriced {
+			// Calls to unpriced models are left out of the estimate
+			cost += " + unpriced"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%v\t%d\t%v\t%s\t\n", name, u.LLMCalls, u.PromptTokens, u.ResponseTokens,
+			float64(u.UploadBytes)/(1<<20), secondsDuration(u.WhisperCPUSeconds), u.TesseractFrames, secondsDuration(u.WallSeconds), cost)
+	}
+	var total UsageTotals
+	var unpriced []string
+	for _, r := range reports {
+		row(filepath.Base(r.VideoPath), r.Usage, len(r.UnpricedModels) > 0)
+		total.add(r.Usage)
+		for _, m := range r.UnpricedModels {
+			if !slices.Contains(unpriced, m) {
+				unpriced = append(unpriced, m)
+			}
+		}
+	}
+	row("TOTAL", total, len(unpriced) > 0)
+	tw.Flush()
+	if len(unpriced) > 0 {
+		fmt.Fprintf(w, "Unpriced model(s) %s: their cost is not included. Add them to --price-table to price them.\n", strings.Join(unpriced, ", "))
+	}
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
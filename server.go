@@ -0,0 +1,932 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultMaxUploadBytes = 4 << 30
+
+// maxSubmitBodyBytes bounds a JSON job submission, which only names a URL or path and options
+const maxSubmitBodyBytes = 1 << 20
+
+// jobPollInterval is how often idle workers look for jobs that are due for a retry or were
+// queued by another process, and how often running jobs check whether they were cancelled
+const jobPollInterval = 5 * time.Second
+
+// ServerConfig configures the HTTP API server
+type ServerConfig struct {
+	Addr string
+	// DataDir holds the job store, and uploaded and downloaded videos under jobs/<id>
+	DataDir string
+	// Concurrency is the number of jobs run at once; 1 when unset
+	Concurrency int
+	// Base is the configuration every job starts from (API key, model, whisper setup); each
+	// job's JobOptions override it
+	Base SummaryConfig
+	// PathRoots are the server directories jobs may name with "path"; none disables path jobs
+	PathRoots []string
+	// Token, when set, must be sent as "Authorization: Bearer <token>" on every request
+	Token          string
+	MaxUploadBytes int64
+	// Retry is how often, and after what backoff, a failed job runs again; zero means
+	// DefaultJobRetryPolicy
+	Retry RetryPolicy
+}
+
+// Server runs summary jobs submitted over HTTP, one SummarizeVideos run per job
+type Server struct {
+	cfg   ServerConfig
+	store JobStore
+	// wake tells idle workers a job was queued
+	wake chan struct{}
+	// transcribers are shared by the jobs, so the bindings backend loads its model once
+	transcribers transcriberPool
+
+	mu      sync.Mutex
+	running map[string]*runningJob
+}
+
+// runningJob is the live state of a job being worked on
+type runningJob struct {
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// progress holds the latest event per video and stage
+	progress    map[string]ProgressEvent
+	subscribers map[chan jobEvent]struct{}
+}
+
+// jobEvent is sent to /events subscribers as a server-sent event
+type jobEvent struct {
+	Type string
+	Data any
+}
+
+// NewServer returns a server taking its jobs from store
+func NewServer(cfg ServerConfig, store JobStore) *Server {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.MaxUploadBytes <= 0 {
+		cfg.MaxUploadBytes = defaultMaxUploadBytes
+	}
+	if cfg.Retry == (RetryPolicy{}) {
+		cfg.Retry = DefaultJobRetryPolicy
+	}
+	return &Server{cfg: cfg, store: store, wake: make(chan struct{}, cfg.Concurrency), running: map[string]*runningJob{}}
+}
+
+// transcriberKey is what job options may change about an AudioTranscriber
+type transcriberKey struct {
+	language    string
+	translate   bool
+	diarization string
+}
+
+// transcriberPool keeps one AudioTranscriber per transcriberKey for the life of the server
+type transcriberPool struct {
+	mu    sync.Mutex
+	byKey map[transcriberKey]AudioTranscriber
+}
+
+// get returns the transcriber for cfg's whisper settings, creating it on first use
+func (p *transcriberPool) get(cfg *SummaryConfig) (AudioTranscriber, error) {
+	key := transcriberKey{language: cfg.WhisperLanguage, translate: cfg.WhisperTranslate, diarization: cfg.Diarization}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.byKey[key]; ok {
+		return t, nil
+	}
+	t, err := newAudioTranscriber(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if p.byKey == nil {
+		p.byKey = map[transcriberKey]AudioTranscriber{}
+	}
+	p.byKey[key] = t
+	return t, nil
+}
+
+// close releases every transcriber; no job may be running
+func (p *transcriberPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, t := range p.byKey {
+		t.Close()
+		delete(p.byKey, key)
+	}
+}
+
+// Handler returns the REST API:
+//
+//	POST   /jobs                            submit a job (multipart upload, or JSON with url or path)
+//	GET    /jobs                            list jobs
+//	GET    /jobs/{id}                       job status, results and live progress
+//	GET    /jobs/{id}/events                stream progress, transcripts and summaries (server-sent events)
+//	GET    /jobs/{id}/videos/{index}/{file} download an output file, e.g. summary or audio_transcript
+//	DELETE /jobs/{id}                       cancel a queued or running job
+//	POST   /jobs/{id}/retry                 queue a failed or cancelled job again
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGet)
+	mux.HandleFunc("GET /jobs/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /jobs/{id}/videos/{index}/{file}", s.handleFile)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	mux.HandleFunc("POST /jobs/{id}/retry", s.handleRetry)
+	if s.cfg.Token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Run serves the API on cfg.Addr and works through the queue until ctx is cancelled. Jobs left
+// running by a previous server are queued again; jobs interrupted by the shutdown are too.
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.requeueInterrupted(); err != nil {
+		return err
+	}
+	var workers sync.WaitGroup
+	for range s.cfg.Concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			s.worker(ctx)
+		}()
+	}
+	s.notify()
+
+	httpServer := &http.Server{Addr: s.cfg.Addr, Handler: s.Handler()}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.ListenAndServe() }()
+	slog.Info("Serving the job API", "addr", s.cfg.Addr, "concurrency", s.cfg.Concurrency)
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		err = httpServer.Shutdown(shutdownCtx)
+	}
+	workers.Wait()
+	s.transcribers.close()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+func (s *Server) requeueInterrupted() error {
+	jobs, err := s.store.List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		from := job.Status
+		switch {
+		case job.Status == JobRunning:
+			job.Status = JobQueued
+		case job.Status == JobCancelled && job.FinishedAt.IsZero():
+			// Cancelled while running, but its worker never recorded the end
+			job.Error, job.FinishedAt = "cancelled", time.Now()
+		default:
+			continue
+		}
+		if err := s.store.Update(job, from); err != nil && !errors.Is(err, ErrJobState) {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify wakes an idle worker, if any
+func (s *Server) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) worker(ctx context.Context) {
+	for {
+		job, rj, err := s.claim()
+		if err != nil {
+			slog.Error("Error claiming a job", "error", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			case <-time.After(jobPollInterval):
+			}
+			continue
+		}
+		s.runJob(ctx, job, rj)
+	}
+}
+
+// claim marks the oldest due job as running and returns it, or nil when there is none
+func (s *Server) claim() (*Job, *runningJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, err := s.store.Claim(time.Now())
+	if job == nil || err != nil {
+		return nil, nil, err
+	}
+	rj := &runningJob{progress: map[string]ProgressEvent{}, subscribers: map[chan jobEvent]struct{}{}}
+	s.running[job.ID] = rj
+	return job, rj, nil
+}
+
+// runJob summarizes a claimed job's input and records the outcome in the store
+func (s *Server) runJob(ctx context.Context, job *Job, rj *runningJob) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rj.mu.Lock()
+	rj.cancel = cancel
+	rj.mu.Unlock()
+	logger := slog.Default().With("job", job.ID)
+	logger.Info("Starting job", "input", job.Input, "attempt", job.Attempts)
+	go s.watchCancel(jobCtx, job.ID, cancel)
+
+	results, err := s.summarizeJob(jobCtx, job, rj, logger)
+	job.Results = newJobResults(results)
+	job.FinishedAt = time.Now()
+	job.NextAttemptAt = time.Time{}
+	switch {
+	case err == nil:
+		job.Status, job.Error = JobSucceeded, ""
+	case jobCtx.Err() != nil && ctx.Err() == nil:
+		job.Status, job.Error = JobCancelled, "cancelled"
+	case ctx.Err() != nil:
+		// The server is shutting down: run the job again after the restart, without counting
+		// the interrupted attempt
+		job.Status, job.StartedAt, job.FinishedAt, job.Results = JobQueued, time.Time{}, time.Time{}, nil
+		job.Attempts--
+	case job.Attempts <= s.cfg.Retry.MaxRetries:
+		job.Status, job.Error = JobQueued, err.Error()
+		job.NextAttemptAt = job.FinishedAt.Add(s.cfg.Retry.equalJitterDelay(job.Attempts - 1))
+		logger.Warn("Job failed, retrying later", "error", err, "retry_at", job.NextAttemptAt)
+	default:
+		job.Status, job.Error = JobFailed, err.Error()
+	}
+
+	s.mu.Lock()
+	delete(s.running, job.ID)
+	err = s.store.Update(job, JobRunning)
+	if errors.Is(err, ErrJobState) {
+		// Cancelled while it ran, by the API or "jobs cancel": the job stays cancelled unless
+		// it completed anyway, and gets the FinishedAt that allows retrying it
+		if job.Status != JobSucceeded {
+			job.Status, job.Error, job.NextAttemptAt = JobCancelled, "cancelled", time.Time{}
+		}
+		if job.FinishedAt.IsZero() {
+			job.FinishedAt = time.Now()
+		}
+		err = s.store.Update(job, JobCancelled)
+	}
+	if err != nil {
+		logger.Error("Error saving job", "error", err)
+	}
+	s.mu.Unlock()
+	logger.Info("Job finished", "status", job.Status, "error", job.Error)
+	rj.publish(jobEvent{Type: "job", Data: job})
+	rj.closeSubscribers()
+}
+
+// watchCancel stops a job when it is marked cancelled in the store, e.g. by "jobs cancel"
+func (s *Server) watchCancel(ctx context.Context, id string, cancel context.CancelFunc) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if job, err := s.store.Get(id); err == nil && job.Status == JobCancelled {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) summarizeJob(ctx context.Context, job *Job, rj *runningJob, logger *slog.Logger) ([]*Result, error) {
+	input := job.Input
+	if job.InputKind == JobInputURL {
+		path, err := YoutubeDownloader(ctx, job.Input, s.jobDir(job.ID))
+		if err != nil {
+			return nil, fmt.Errorf("error downloading %s: %w", job.Input, err)
+		}
+		input = path
+	}
+	cfg := s.cfg.Base
+	job.Options.apply(&cfg)
+	cfg.InputPath = input
+	cfg.Logger = logger
+	cfg.Progress = ProgressFunc(rj.onProgress)
+	cfg.Observer = jobObserver{rj}
+	if cfg.AudioTranscriber == nil && !cfg.VideoOnly {
+		transcriber, err := s.transcribers.get(&cfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.AudioTranscriber = transcriber
+	}
+	return SummarizeVideos(ctx, cfg)
+}
+
+func (s *Server) jobDir(id string) string {
+	return filepath.Join(s.cfg.DataDir, "jobs", id)
+}
+
+// submitRequest is the JSON body of POST /jobs; exactly one of URL and Path is set
+type submitRequest struct {
+	URL     string     `json:"url"`
+	Path    string     `json:"path"`
+	Options JobOptions `json:"options"`
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	job := &Job{ID: newJobID(), Status: JobQueued, CreatedAt: time.Now()}
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = s.receiveUpload(w, r, job)
+	} else {
+		err = s.parseSubmit(w, r, job)
+	}
+	if err != nil {
+		os.RemoveAll(s.jobDir(job.ID))
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.store.Create(job); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.notify()
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// receiveUpload saves the multipart "file" field under the job's directory. Job options may be
+// sent as JSON in the "options" field.
+func (s *Server) receiveUpload(w http.ResponseWriter, r *http.Request, job *Job) error {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxUploadBytes)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading upload: %w", err)
+		}
+		switch part.FormName() {
+		case "options":
+			if err := json.NewDecoder(part).Decode(&job.Options); err != nil {
+				return fmt.Errorf("error parsing options: %w", err)
+			}
+		case "file":
+			name := filepath.Base(part.FileName())
+			if !IsVideoFile(name) {
+				return fmt.Errorf("%q is not a video file", part.FileName())
+			}
+			if err := os.MkdirAll(s.jobDir(job.ID), 0755); err != nil {
+				return err
+			}
+			path := filepath.Join(s.jobDir(job.ID), name)
+			if err := saveUpload(path, part); err != nil {
+				return err
+			}
+			job.InputKind, job.Input = JobInputUpload, path
+		}
+	}
+	if job.Input == "" {
+		return errors.New(`multipart request has no "file" field`)
+	}
+	return nil
+}
+
+func saveUpload(path string, src io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	if _, err := io.Copy(file, src); err != nil {
+		file.Close()
+		return fmt.Errorf("error saving upload: %w", err)
+	}
+	return file.Close()
+}
+
+func (s *Server) parseSubmit(w http.ResponseWriter, r *http.Request, job *Job) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubmitBodyBytes)
+	var req submitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("error parsing request: %w", err)
+	}
+	job.Options = req.Options
+	switch {
+	case req.URL != "" && req.Path != "":
+		return errors.New(`set only one of "url" and "path"`)
+	case req.URL != "":
+		if !isValidYoutubeURL(req.URL) {
+			return fmt.Errorf("unsupported URL %s", req.URL)
+		}
+		job.InputKind, job.Input = JobInputURL, req.URL
+	case req.Path != "":
+		path, err := s.allowedPath(req.Path)
+		if err != nil {
+			return err
+		}
+		job.InputKind, job.Input = JobInputPath, path
+	default:
+		return errors.New(`set "url" or "path", or upload a file as multipart/form-data`)
+	}
+	return nil
+}
+
+// allowedPath resolves path, following symlinks, and checks it lies under one of cfg.PathRoots
+func (s *Server) allowedPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("error accessing %s: %w", path, err)
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range s.cfg.PathRoots {
+		root, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("path %s is outside the allowed directories", path)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.store.List()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	views := make([]jobView, 0, len(jobs))
+	for _, job := range jobs {
+		views = append(views, s.view(job))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// jobView is a job as returned by the API, with the live progress of a running job
+type jobView struct {
+	*Job
+	Progress []progressView `json:"progress,omitempty"`
+}
+
+type progressView struct {
+	VideoPath       string  `json:"video_path"`
+	VideoIndex      int     `json:"video_index"`
+	VideoCount      int     `json:"video_count"`
+	Stage           string  `json:"stage"`
+	Done            int     `json:"done"`
+	Total           int     `json:"total"`
+	ElapsedSeconds  float64 `json:"elapsed_seconds"`
+	StageETASeconds float64 `json:"stage_eta_seconds,omitempty"`
+	VideoETASeconds float64 `json:"video_eta_seconds,omitempty"`
+}
+
+func newProgressView(e ProgressEvent) progressView {
+	return progressView{
+		VideoPath: e.VideoPath, VideoIndex: e.VideoIndex, VideoCount: e.VideoCount, Stage: e.Stage, Done: e.Done, Total: e.Total,
+		ElapsedSeconds: e.Elapsed.Seconds(), StageETASeconds: e.StageETA.Seconds(), VideoETASeconds: e.VideoETA.Seconds(),
+	}
+}
+
+func (s *Server) view(job *Job) jobView {
+	v := jobView{Job: job}
+	s.mu.Lock()
+	rj := s.running[job.ID]
+	s.mu.Unlock()
+	if rj != nil {
+		rj.mu.Lock()
+		for _, stage := range progressStages {
+			for _, e := range rj.progress {
+				if e.Stage == stage {
+					v.Progress = append(v.Progress, newProgressView(e))
+				}
+			}
+		}
+		rj.mu.Unlock()
+	}
+	return v
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	job, err := s.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.view(job))
+}
+
+// handleEvents streams a running job's progress, transcripts and summaries as server-sent
+// events, ending with a "job" event holding the finished job
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	s.mu.Lock()
+	job, err := s.store.Get(r.PathValue("id"))
+	rj := s.running[r.PathValue("id")]
+	var events chan jobEvent
+	if rj != nil {
+		events = rj.subscribe()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	writeServerSentEvent(w, jobEvent{Type: "job", Data: s.view(job)})
+	flusher.Flush()
+	if events == nil {
+		return
+	}
+	defer rj.unsubscribe(events)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			writeServerSentEvent(w, e)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeServerSentEvent(w io.Writer, e jobEvent) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+}
+
+// handleFile serves one of the output files recorded in a job's results
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	job, err := s.store.Get(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 1 || index > len(job.Results) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("job %s has no video %s", job.ID, r.PathValue("index")))
+		return
+	}
+	files := job.Results[index-1].Files
+	path := map[string]string{
+		"summary":          files.Summary,
+		"audio_transcript": files.AudioTranscript,
+		"subtitles":        files.Subtitles,
+		"video_transcript": files.VideoTranscript,
+		"chunk_summaries":  files.ChunkSummaries,
+		"report":           files.Report,
+		"log":              files.Log,
+	}[r.PathValue("file")]
+	if path == "" {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("video %d of job %s has no %s file", index, job.ID, r.PathValue("file")))
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), file)
+}
+
+// handleCancel cancels a queued job, or stops a running one
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, err := cancelJob(s.store, r.PathValue("id"))
+	if err == nil && job.Status == JobCancelled {
+		if rj := s.running[job.ID]; rj != nil {
+			rj.mu.Lock()
+			if rj.cancel != nil {
+				rj.cancel()
+			}
+			rj.mu.Unlock()
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.view(job))
+}
+
+// handleRetry queues a failed or cancelled job again
+func (s *Server) handleRetry(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	var job *Job
+	err := fmt.Errorf("%w: job %s is still stopping", ErrJobState, id)
+	if s.running[id] == nil {
+		job, err = retryJob(s.store, id)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	s.notify()
+	writeJSON(w, http.StatusAccepted, s.view(job))
+}
+
+func (rj *runningJob) onProgress(e ProgressEvent) {
+	rj.mu.Lock()
+	rj.progress[fmt.Sprintf("%d/%s", e.VideoIndex, e.Stage)] = e
+	rj.mu.Unlock()
+	rj.publish(jobEvent{Type: "progress", Data: newProgressView(e)})
+}
+
+func (rj *runningJob) subscribe() chan jobEvent {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	events := make(chan jobEvent, 64)
+	rj.subscribers[events] = struct{}{}
+	return events
+}
+
+func (rj *runningJob) unsubscribe(events chan jobEvent) {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	if _, ok := rj.subscribers[events]; ok {
+		delete(rj.subscribers, events)
+		close(events)
+	}
+}
+
+// publish sends e to every subscriber, dropping it for those that are not keeping up
+func (rj *runningJob) publish(e jobEvent) {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	for events := range rj.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+func (rj *runningJob) closeSubscribers() {
+	rj.mu.Lock()
+	defer rj.mu.Unlock()
+	for events := range rj.subscribers {
+		delete(rj.subscribers, events)
+		close(events)
+	}
+}
+
+// jobObserver forwards pipeline events to a job's subscribers
+type jobObserver struct {
+	rj *runningJob
+}
+
+// chunkEventData is the payload of the chunk-level events of /events
+type chunkEventData struct {
+	VideoPath     string  `json:"video_path"`
+	VideoIndex    int     `json:"video_index"`
+	ChunkNum      int     `json:"chunk"`
+	OffsetSeconds float64 `json:"offset_seconds"`
+	Text          string  `json:"text,omitempty"`
+	Language      string  `json:"language,omitempty"`
+	Silent        bool    `json:"silent,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+func newChunkEventData(c ChunkInfo) chunkEventData {
+	return chunkEventData{VideoPath: c.VideoPath, VideoIndex: c.VideoIndex, ChunkNum: c.ChunkNum, OffsetSeconds: c.Offset.Seconds()}
+}
+
+func (o jobObserver) OnChunkCreated(c ChunkInfo) {
+	o.rj.publish(jobEvent{Type: "chunk_created", Data: newChunkEventData(c)})
+}
+
+func (o jobObserver) OnAudioTranscribed(t ChunkAudioTranscript) {
+	data := newChunkEventData(t.ChunkInfo)
+	data.Text, data.Language, data.Silent = t.Text, t.Language, t.Silent
+	o.rj.publish(jobEvent{Type: "audio_transcribed", Data: data})
+}
+
+func (o jobObserver) OnVideoTranscribed(t ChunkVideoTranscript) {
+	data := newChunkEventData(t.ChunkInfo)
+	data.Text = t.Text
+	o.rj.publish(jobEvent{Type: "video_transcribed", Data: data})
+}
+
+func (o jobObserver) OnSummaryChunk(s SummaryChunk) {
+	o.rj.publish(jobEvent{Type: "summary", Data: chunkEventData{VideoPath: s.VideoPath, VideoIndex: s.VideoIndex, ChunkNum: s.ChunkNum, Text: s.Text}})
+}
+
+func (o jobObserver) OnError(e *PipelineError) {
+	o.rj.publish(jobEvent{Type: "error", Data: chunkEventData{VideoPath: e.VideoPath, VideoIndex: e.VideoIndex, ChunkNum: e.ChunkNum, Error: e.Err.Error()}})
+}
+
+func (o jobObserver) OnVideoDone(r *Result) {
+	o.rj.publish(jobEvent{Type: "video_done", Data: newJobResults([]*Result{r})[0]})
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeJSONError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrJobState):
+		writeJSONError(w, http.StatusConflict, err)
+	default:
+		writeJSONError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// isLoopbackAddr reports whether the listen address addr only accepts connections from this host
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveMain is the "serve" command: run the job API until interrupted
+func serveMain(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on; a non-loopback address needs VIDEO_SUMMARY_API_TOKEN or --insecure-no-auth")
+	insecureNoAuth := fs.Bool("insecure-no-auth", false, "allow serving on a non-loopback address without VIDEO_SUMMARY_API_TOKEN")
+	dataDir := fs.String("data-dir", defaultDataDir, "directory for the job queue and uploaded or downloaded videos")
+	concurrency := fs.Int("jobs", 1, "number of jobs to run at once")
+	allowPaths := fs.String("allow-path", "", "comma-separated server directories that jobs may reference with \"path\"")
+	jobRetries := fs.Int("job-retries", DefaultJobRetryPolicy.MaxRetries, "times a failed job is run again")
+	jobRetryDelay := fs.Duration("job-retry-delay", DefaultJobRetryPolicy.BaseDelay, "backoff before the first retry of a failed job, doubled for each further retry")
+	maxUploadMB := fs.Int64("max-upload-mb", defaultMaxUploadBytes>>20, "largest accepted upload in MiB")
+	llm := fs.String("llm", "gemini-2.0-flash", "default Gemini model; jobs may override it")
+	chunkDuration := fs.Int("chunk-duration", 300, "default chunk length in seconds")
+	whisperCLIPath := fs.String("whisper-cli", "whisper-cli", "whisper-cli binary for the cli backend")
+	whisperModelPath := fs.String("whisper-model", "", "whisper model file")
+	whisperThreads := fs.Int("whisper-threads", 4, "whisper threads per chunk")
+	whisperLanguage := fs.String("whisper-language", AutoDetectLanguage, "default spoken language, or auto")
+	whisperBackend := fs.String("whisper-backend", WhisperBackendCLI, "audio transcription backend: cli, bindings or server")
+	whisperServerURL := fs.String("whisper-server-url", "", "endpoint for the server backend")
+	rpm := fs.Int("rpm", 0, "max Gemini requests per minute across all jobs; 0 is unlimited")
+	tpm := fs.Int("tpm", 0, "max Gemini prompt tokens per minute across all jobs; 0 is unlimited")
+	dailyTokenBudget := fs.Int64("daily-token-budget", 0, "max Gemini tokens per day; 0 is unlimited")
+	buildNotifiers := notifierFlags(fs)
+	verbose := fs.Bool("verbose", false, "also log debug detail")
+	logJSON := fs.Bool("log-json", false, "write log records as JSON")
+	fs.Parse(args)
+
+	logOpts := LogOptions{Level: slog.LevelInfo, JSON: *logJSON}
+	if *verbose {
+		logOpts.Level = slog.LevelDebug
+	}
+	slog.SetDefault(NewLogger(os.Stderr, logOpts))
+
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		log.Fatalf("Set GEMINI_API_KEY to the Gemini API key jobs should use\n")
+	}
+	// Anyone who can reach the API can spend the Gemini quota and read job outputs
+	token := os.Getenv("VIDEO_SUMMARY_API_TOKEN")
+	if token == "" && !isLoopbackAddr(*addr) && !*insecureNoAuth {
+		log.Fatalf("Refusing to serve on %s without authentication: set VIDEO_SUMMARY_API_TOKEN, listen on a loopback address such as localhost:8080, or pass --insecure-no-auth\n", *addr)
+	}
+	// Share one limiter so the quota holds across concurrent jobs
+	quota, err := NewQuotaLimiter(QuotaConfig{RequestsPerMinute: *rpm, TokensPerMinute: *tpm, DailyTokenBudget: *dailyTokenBudget})
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	notifiers, err := buildNotifiers()
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	var roots []string
+	for _, root := range strings.Split(*allowPaths, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, root)
+		}
+	}
+
+	store, err := NewSQLiteJobStore(jobStorePath(*dataDir))
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	defer store.Close()
+	server := NewServer(ServerConfig{
+		Addr:           *addr,
+		DataDir:        *dataDir,
+		Concurrency:    *concurrency,
+		PathRoots:      roots,
+		Token:          token,
+		MaxUploadBytes: *maxUploadMB << 20,
+		Retry:          RetryPolicy{MaxRetries: *jobRetries, BaseDelay: *jobRetryDelay, MaxDelay: DefaultJobRetryPolicy.MaxDelay},
+		Base: SummaryConfig{
+			LLM:                 *llm,
+			APIKey:              apiKey,
+			ChunkDuration:       *chunkDuration,
+			WhisperCLIPath:      *whisperCLIPath,
+			WhisperModelPath:    *whisperModelPath,
+			WhisperThreads:      *whisperThreads,
+			WhisperLanguage:     *whisperLanguage,
+			WhisperBackend:      *whisperBackend,
+			WhisperServerURL:    *whisperServerURL,
+			WhisperServerAPIKey: os.Getenv("WHISPER_SERVER_API_KEY"),
+			QuotaLimiter:        quota,
+			Notifiers:           notifiers,
+		},
+	}, store)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Run(ctx); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
@@ -0,0 +1,321 @@
+package videoSummaryGo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server on a fresh job store, and a client for its API
+func newTestServer(t *testing.T, cfg ServerConfig) (*Server, *httptest.Server) {
+	t.Helper()
+	cfg.DataDir = t.TempDir()
+	store, err := NewSQLiteJobStore(jobStorePath(cfg.DataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	s := NewServer(cfg, store)
+	api := httptest.NewServer(s.Handler())
+	t.Cleanup(api.Close)
+	return s, api
+}
+
+func doRequest(t *testing.T, method, url, token, contentType string, body io.Reader) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodeJob(t *testing.T, resp *http.Response) *Job {
+	t.Helper()
+	var job Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestServerAuth(t *testing.T) {
+	_, api := newTestServer(t, ServerConfig{Token: "s3cret"})
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "guess", http.StatusUnauthorized},
+		{"token", "s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doRequest(t, http.MethodGet, api.URL+"/jobs", tt.token, "", nil)
+			if resp.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	_, open := newTestServer(t, ServerConfig{})
+	if resp := doRequest(t, http.MethodGet, open.URL+"/jobs", "", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("server without a token answered %d", resp.StatusCode)
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	tests := map[string]bool{
+		"localhost:8080": true, // the serve default
+		"127.0.0.1:8080": true,
+		"127.0.0.2:80":   true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"[::]:8080":      false,
+		"192.168.1.5:80": false,
+		"example.com:80": false,
+		"localhost":      false,
+	}
+	for addr, want := range tests {
+		if got := isLoopbackAddr(addr); got != want {
+			t.Errorf("isLoopbackAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestServerSubmitPath(t *testing.T) {
+	root := t.TempDir()
+	video := filepath.Join(root, "talk.mp4")
+	if err := os.WriteFile(video, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, api := newTestServer(t, ServerConfig{PathRoots: []string{root}})
+
+	resp := doRequest(t, http.MethodPost, api.URL+"/jobs", "", "application/json", strings.NewReader(`{"path": "`+video+`", "options": {"prompt": "focus on the demo"}}`))
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	job := decodeJob(t, resp)
+	if job.Status != JobQueued || job.InputKind != JobInputPath || job.Options.Prompt != "focus on the demo" || resp.Header.Get("Location") != "/jobs/"+job.ID {
+		t.Errorf("got job %+v at %s", job, resp.Header.Get("Location"))
+	}
+	if stored, err := s.store.Get(job.ID); err != nil || stored.Status != JobQueued {
+		t.Errorf("stored job %+v, %v", stored, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret.mp4")
+	os.WriteFile(outside, nil, 0644)
+	if resp := doRequest(t, http.MethodPost, api.URL+"/jobs", "", "application/json", strings.NewReader(`{"path": "`+outside+`"}`)); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("path outside the allowed roots got status %d", resp.StatusCode)
+	}
+}
+
+func TestServerBodyLimits(t *testing.T) {
+	s, api := newTestServer(t, ServerConfig{MaxUploadBytes: 1024})
+
+	// A JSON submission is bounded by maxSubmitBodyBytes whatever MaxUploadBytes is
+	big := `{"url": "https://www.youtube.com/watch?v=abc", "options": {"prompt": "` + strings.Repeat("x", maxSubmitBodyBytes) + `"}}`
+	if resp := doRequest(t, http.MethodPost, api.URL+"/jobs", "", "application/json", strings.NewReader(big)); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("oversized JSON body got status %d", resp.StatusCode)
+	}
+
+	upload := func(size int) *http.Response {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "talk.mp4")
+		part.Write(bytes.Repeat([]byte{0}, size))
+		form.Close()
+		return doRequest(t, http.MethodPost, api.URL+"/jobs", "", form.FormDataContentType(), &body)
+	}
+	if resp := upload(4096); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("oversized upload got status %d", resp.StatusCode)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(s.cfg.DataDir, "jobs")); len(entries) != 0 {
+		t.Errorf("a rejected upload left %d job directories", len(entries))
+	}
+	resp := upload(512)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("upload within the limit got status %d", resp.StatusCode)
+	}
+	if job := decodeJob(t, resp); job.InputKind != JobInputUpload {
+		t.Errorf("got job %+v", job)
+	}
+}
+
+// startRunningJob queues a job and claims it, as a worker would, with a cancel that records the call
+func startRunningJob(t *testing.T, s *Server) (*Job, *runningJob, *atomic.Bool) {
+	t.Helper()
+	if err := s.store.Create(&Job{ID: newJobID(), Status: JobQueued, InputKind: JobInputPath, Input: "/videos", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	job, rj, err := s.claim()
+	if err != nil || job == nil {
+		t.Fatalf("claim: %v, %v", job, err)
+	}
+	cancelled := new(atomic.Bool)
+	rj.cancel = func() { cancelled.Store(true) }
+	return job, rj, cancelled
+}
+
+func TestServerEvents(t *testing.T) {
+	s, api := newTestServer(t, ServerConfig{})
+	job, rj, _ := startRunningJob(t, s)
+
+	resp := doRequest(t, http.MethodGet, api.URL+"/jobs/"+job.ID+"/events", "", "", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	events := bufio.NewReader(resp.Body)
+	readEvent := func() (string, string) {
+		t.Helper()
+		var name, data string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("stream ended: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return name, data
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	if name, data := readEvent(); name != "job" || !strings.Contains(data, `"status":"running"`) {
+		t.Errorf("first event is %s %s, want the running job", name, data)
+	}
+	rj.onProgress(ProgressEvent{VideoPath: "/videos/talk.mp4", VideoIndex: 1, VideoCount: 1, Stage: ProgressStageWhisper, Done: 1, Total: 3})
+	if name, data := readEvent(); name != "progress" || !strings.Contains(data, `"stage":"whisper"`) || !strings.Contains(data, `"done":1`) {
+		t.Errorf("got %s %s, want the whisper progress", name, data)
+	}
+	jobObserver{rj}.OnSummaryChunk(SummaryChunk{VideoPath: "/videos/talk.mp4", VideoIndex: 1, ChunkNum: -1, Text: "A talk."})
+	if name, data := readEvent(); name != "summary" || !strings.Contains(data, `"text":"A talk."`) {
+		t.Errorf("got %s %s, want the summary", name, data)
+	}
+
+	// The stream ends when the job does
+	rj.closeSubscribers()
+	if rest, err := io.ReadAll(events); err != nil || len(rest) != 0 {
+		t.Errorf("stream went on with %q, %v", rest, err)
+	}
+
+	// A job that is not running gets its state and nothing more
+	finished := &Job{ID: newJobID(), Status: JobSucceeded, CreatedAt: time.Now()}
+	s.store.Create(finished)
+	resp = doRequest(t, http.MethodGet, api.URL+"/jobs/"+finished.ID+"/events", "", "", nil)
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(string(body), "event: job\n") || strings.Count(string(body), "event: ") != 1 {
+		t.Errorf("got %q", body)
+	}
+	if resp := doRequest(t, http.MethodGet, api.URL+"/jobs/unknown/events", "", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job got status %d", resp.StatusCode)
+	}
+}
+
+func TestServerCancel(t *testing.T) {
+	s, api := newTestServer(t, ServerConfig{})
+
+	queued := &Job{ID: newJobID(), Status: JobQueued, CreatedAt: time.Now()}
+	s.store.Create(queued)
+	resp := doRequest(t, http.MethodDelete, api.URL+"/jobs/"+queued.ID, "", "", nil)
+	if job := decodeJob(t, resp); resp.StatusCode != http.StatusAccepted || job.Status != JobCancelled || job.FinishedAt.IsZero() {
+		t.Errorf("queued job: status %d, job %+v", resp.StatusCode, job)
+	}
+	// Cancelling again conflicts
+	if resp := doRequest(t, http.MethodDelete, api.URL+"/jobs/"+queued.ID, "", "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("cancelling a cancelled job got status %d", resp.StatusCode)
+	}
+
+	running, _, cancelled := startRunningJob(t, s)
+	resp = doRequest(t, http.MethodDelete, api.URL+"/jobs/"+running.ID, "", "", nil)
+	if resp.StatusCode != http.StatusAccepted || !cancelled.Load() {
+		t.Errorf("running job: status %d, cancelled %v", resp.StatusCode, cancelled.Load())
+	}
+	// Its worker records the end; until then it cannot be retried
+	if resp := doRequest(t, http.MethodPost, api.URL+"/jobs/"+running.ID+"/retry", "", "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("retrying a stopping job got status %d", resp.StatusCode)
+	}
+
+	if resp := doRequest(t, http.MethodDelete, api.URL+"/jobs/unknown", "", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job got status %d", resp.StatusCode)
+	}
+}
+
+func TestServerSharesTranscribers(t *testing.T) {
+	var pool transcriberPool
+	defer pool.close()
+	cfg := SummaryConfig{WhisperBackend: WhisperBackendCLI, WhisperLanguage: "en"}
+	first, err := pool.get(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := pool.get(&cfg)
+	if again != first {
+		t.Error("a second job with the same settings got a new transcriber")
+	}
+	// Options that change the transcriber get their own
+	cfg.WhisperLanguage = "de"
+	if other, _ := pool.get(&cfg); other == first {
+		t.Error("a job with another language shares the transcriber")
+	}
+}
+
+func TestJobRetryDelayHasFloor(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	for attempt := range 3 {
+		backoff := time.Minute << attempt
+		for range 200 {
+			if d := policy.equalJitterDelay(attempt); d < backoff/2 || d > backoff {
+				t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, d, backoff/2, backoff)
+			}
+		}
+	}
+	if d := policy.equalJitterDelay(10); d < 30*time.Minute || d > time.Hour {
+		t.Errorf("delay %v is not capped at MaxDelay", d)
+	}
+}
+
+func TestSummarizeJobUsesSharedTranscriber(t *testing.T) {
+	s, _ := newTestServer(t, ServerConfig{Base: SummaryConfig{WhisperBackend: WhisperBackendCLI}})
+	job := &Job{ID: newJobID(), InputKind: JobInputPath, Input: filepath.Join(t.TempDir(), "missing")}
+	rj := &runningJob{progress: map[string]ProgressEvent{}, subscribers: map[chan jobEvent]struct{}{}}
+	for range 2 {
+		// The run fails early on the missing input; the transcriber was taken from the pool
+		s.summarizeJob(quietContext(), job, rj, logFrom(quietContext()))
+	}
+	if n := len(s.transcribers.byKey); n != 1 {
+		t.Errorf("two jobs left %d transcribers in the pool, want 1", n)
+	}
+	s.transcribers.close()
+	if n := len(s.transcribers.byKey); n != 0 {
+		t.Errorf("%d transcribers left after close", n)
+	}
+}
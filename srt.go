@@ -0,0 +1,50 @@
+package videoSummaryGo
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// srtWriter appends transcript segments to a SubRip file. Segment times are relative to their
+// chunk, so each call passes the chunk's offset into the video.
+type srtWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	index int
+}
+
+func newSRTWriter(w io.Writer) *srtWriter {
+	return &srtWriter{w: w}
+}
+
+// WriteSegments writes the timed segments of one chunk; untimed segments are skipped.
+// A nil writer (video-only runs) discards them.
+func (s *srtWriter) WriteSegments(offset time.Duration, segments []TranscriptSegment) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, seg := range segments {
+		if seg.Start == 0 && seg.End == 0 {
+			continue
+		}
+		s.index++
+		text := seg.Text
+		if seg.Speaker != "" {
+			text = seg.Speaker + ": " + text
+		}
+		if _, err := fmt.Fprintf(s.w, "%d\n%s --> %s\n%s\n\n", s.index, formatSRTTimestamp(offset+seg.Start), formatSRTTimestamp(offset+seg.End), text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatSRTTimestamp formats d as SubRip's hh:mm:ss,mmm
+func formatSRTTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
@@ -0,0 +1,160 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TranscriptSegment is one timed piece of an audio chunk transcript
+type TranscriptSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+	// Speaker is a diarization label such as "Speaker 1" (or a mapped real name), empty if unknown
+	Speaker string
+}
+
+// AudioTranscript is the result of transcribing one audio chunk
+type AudioTranscript struct {
+	// Language is the spoken language, as detected by whisper when the configured language is "auto"
+	Language string
+	Segments []TranscriptSegment
+	// CPUTime is the CPU time whisper used, for backends that can measure it (whisper-cli); zero otherwise
+	CPUTime time.Duration
+}
+
+// AudioTranscriber turns one WAV audio chunk into timed transcript segments.
+// Implementations must be safe for concurrent use by chunk workers.
+type AudioTranscriber interface {
+	TranscribeAudio(ctx context.Context, audioPath string, videoIndex int, chunkNum int) (AudioTranscript, error)
+	Close() error
+}
+
+// AutoDetectLanguage asks whisper to detect the spoken language of every chunk
+const AutoDetectLanguage = "auto"
+
+// Whisper backends selectable through SummaryConfig.WhisperBackend
+const (
+	WhisperBackendCLI      = "cli"
+	WhisperBackendBindings = "bindings"
+	WhisperBackendServer   = "server"
+)
+
+// newAudioTranscriber builds the AudioTranscriber selected by cfg.WhisperBackend
+func newAudioTranscriber(cfg *SummaryConfig) (AudioTranscriber, error) {
+	switch cfg.WhisperBackend {
+	case "", WhisperBackendCLI:
+		return &whisperCLITranscriber{
+			cliPath:   cfg.WhisperCLIPath,
+			modelPath: cfg.WhisperModelPath,
+			threads:   cfg.WhisperThreads,
+			language:  cfg.WhisperLanguage,
+			translate: cfg.WhisperTranslate,
+			diarize:   cfg.Diarization,
+		}, nil
+	case WhisperBackendBindings:
+		if cfg.Diarization != "" {
+			return nil, fmt.Errorf("diarization %q is not supported by the %q whisper backend, use a Diarizer instead", cfg.Diarization, WhisperBackendBindings)
+		}
+		return newWhisperBindingsTranscriber(cfg.WhisperModelPath, cfg.WhisperThreads, cfg.WhisperLanguage, cfg.WhisperTranslate)
+	case WhisperBackendServer:
+		return newWhisperServerTranscriber(cfg.WhisperServerURL, cfg.WhisperServerAPIKey, cfg.WhisperServerModel, cfg.WhisperLanguage, cfg.WhisperTranslate, cfg.Diarization, nil)
+	default:
+		return nil, fmt.Errorf("unknown whisper backend %q", cfg.WhisperBackend)
+	}
+}
+
+// whisperCLITranscriber forks whisper-cli for every chunk. It reloads the model each time,
+// but needs nothing beyond a whisper.cpp build on disk.
+type whisperCLITranscriber struct {
+	cliPath   string
+	modelPath string
+	threads   int
+	language  string
+	translate bool
+	diarize   string
+}
+
+func (t *whisperCLITranscriber) TranscribeAudio(ctx context.Context, audioPath string, videoIndex int, chunkNum int) (AudioTranscript, error) {
+	stdout, stderr, cpuTime, err := runWhisperCLI(ctx, audioPath, t.cliPath, t.modelPath, videoIndex, chunkNum, t.threads, t.language, t.translate, t.diarize)
+	if err != nil {
+		return AudioTranscript{}, err
+	}
+	language := t.language
+	if language == AutoDetectLanguage {
+		language = parseWhisperDetectedLanguage(stderr)
+	}
+	return AudioTranscript{Language: language, Segments: applySpeakerMarkers(parseWhisperCLIOutput(stdout), t.diarize), CPUTime: cpuTime}, nil
+}
+
+func (t *whisperCLITranscriber) Close() error { return nil }
+
+var whisperCLILineRegex = regexp.MustCompile(`^\[(\d+:\d{2}:\d{2}\.\d{3}) --> (\d+:\d{2}:\d{2}\.\d{3})\]\s*(.*)$`)
+
+// parseWhisperCLIOutput splits whisper-cli stdout ("[00:00:00.000 --> 00:00:04.000]  text") into segments.
+// Lines without a timestamp are kept as untimed segments so no text is lost.
+func parseWhisperCLIOutput(output string) []TranscriptSegment {
+	var segments []TranscriptSegment
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		match := whisperCLILineRegex.FindStringSubmatch(line)
+		if match == nil {
+			segments = append(segments, TranscriptSegment{Text: line})
+			continue
+		}
+		start, _ := parseWhisperTimestamp(match[1])
+		end, _ := parseWhisperTimestamp(match[2])
+		segments = append(segments, TranscriptSegment{Start: start, End: end, Text: strings.TrimSpace(match[3])})
+	}
+	return segments
+}
+
+var whisperDetectedLanguageRegex = regexp.MustCompile(`auto-detected language: (\w+)`)
+
+// parseWhisperDetectedLanguage extracts the language whisper-cli logs to stderr in auto mode
+func parseWhisperDetectedLanguage(stderr string) string {
+	if match := whisperDetectedLanguageRegex.FindStringSubmatch(stderr); match != nil {
+		return match[1]
+	}
+	return AutoDetectLanguage
+}
+
+// parseWhisperTimestamp parses whisper's hh:mm:ss.mmm timestamps
+func parseWhisperTimestamp(ts string) (time.Duration, error) {
+	var h, m, s, ms int
+	if _, err := fmt.Sscanf(ts, "%d:%d:%d.%d", &h, &m, &s, &ms); err != nil {
+		return 0, fmt.Errorf("invalid whisper timestamp %q: %w", ts, err)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second + time.Duration(ms)*time.Millisecond, nil
+}
+
+// formatWhisperTimestamp is the inverse of parseWhisperTimestamp
+func formatWhisperTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, ms%1000)
+}
+
+// formatTranscriptSegments renders segments in whisper-cli's own output format, so the
+// audio output file looks the same whichever backend produced it.
+func formatTranscriptSegments(segments []TranscriptSegment) string {
+	var sb strings.Builder
+	for _, seg := range segments {
+		text := seg.Text
+		if seg.Speaker != "" {
+			text = seg.Speaker + ": " + text
+		}
+		if seg.Start == 0 && seg.End == 0 {
+			sb.WriteString(text)
+		} else {
+			fmt.Fprintf(&sb, "[%s --> %s]  %s", formatWhisperTimestamp(seg.Start), formatWhisperTimestamp(seg.End), text)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package videoSummaryGo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseWhisperCLIOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []TranscriptSegment
+	}{
+		{"empty", "\n  \n", nil},
+		{
+			"timed lines",
+			"[00:00:00.000 --> 00:00:04.500]   Hello there.\n[00:00:04.500 --> 00:01:02.010]  General Kenobi.\n",
+			[]TranscriptSegment{
+				{Start: 0, End: 4500 * time.Millisecond, Text: "Hello there."},
+				{Start: 4500 * time.Millisecond, End: time.Minute + 2010*time.Millisecond, Text: "General Kenobi."},
+			},
+		},
+		{
+			"hours and CRLF",
+			"[01:02:03.004 --> 01:02:05.000]  Late.\r\n",
+			[]TranscriptSegment{{Start: time.Hour + 2*time.Minute + 3004*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "Late."}},
+		},
+		{
+			"untimed lines are kept",
+			"[00:00:00.000 --> 00:00:01.000]  Timed.\n(music)\n",
+			[]TranscriptSegment{{End: time.Second, Text: "Timed."}, {Text: "(music)"}},
+		},
+		{
+			"timestamp without text",
+			"[00:00:01.000 --> 00:00:02.000]\n",
+			[]TranscriptSegment{{Start: time.Second, End: 2 * time.Second}},
+		},
+		{
+			"malformed timestamp",
+			"[00:00 --> 00:01]  Short stamps.\n",
+			[]TranscriptSegment{{Text: "[00:00 --> 00:01]  Short stamps."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWhisperCLIOutput(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTranscriptSegmentsRoundTrip(t *testing.T) {
+	segments := []TranscriptSegment{
+		{Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "Timed."},
+		{Text: "Untimed."},
+		{Start: time.Hour, End: time.Hour + time.Second, Text: "Later."},
+	}
+	if got := parseWhisperCLIOutput(formatTranscriptSegments(segments)); !reflect.DeepEqual(got, segments) {
+		t.Errorf("round trip gave %+v, want %+v", got, segments)
+	}
+}
+
+func TestParseWhisperTimestamp(t *testing.T) {
+	if d, err := parseWhisperTimestamp("00:10:05.250"); err != nil || d != 10*time.Minute+5250*time.Millisecond {
+		t.Errorf("got %v, %v", d, err)
+	}
+	if _, err := parseWhisperTimestamp("garbage"); err == nil {
+		t.Error("no error for an invalid timestamp")
+	}
+}
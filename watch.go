@@ -0,0 +1,264 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWatchInterval  = 10 * time.Second
+	defaultWatchStableFor = 30 * time.Second
+	// watchStateFile, in the watched folder, records the videos already processed
+	watchStateFile = ".video_summary_watch.json"
+)
+
+// WatchConfig configures WatchFolder
+type WatchConfig struct {
+	// Dir is the folder to watch; only videos directly in it are picked up
+	Dir string
+	// Interval is how often Dir is scanned
+	Interval time.Duration
+	// StableFor is how long a video's size and modification time must stay the same before it is
+	// summarized, so files still being copied or recorded are left alone
+	StableFor time.Duration
+	// MoveTo, when set, is where summarized videos are moved together with their output files.
+	// Videos that failed stay in Dir.
+	MoveTo string
+}
+
+// watchedFile is the state file's record of a processed video. A video is processed again only
+// if its size or modification time change, e.g. when a failed recording is replaced.
+type watchedFile struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	ProcessedAt time.Time `json:"processed_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// pendingFile is a video seen in Dir that is waiting to stop changing
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// WatchFolder summarizes every video that appears in watch.Dir, one at a time, until ctx is
+// cancelled. cfg is used for each video, with InputPath set to it. Processed videos are recorded
+// in a state file in the folder, so they are not summarized twice, also across restarts.
+func WatchFolder(ctx context.Context, cfg SummaryConfig, watch WatchConfig) error {
+	if watch.Interval <= 0 {
+		watch.Interval = defaultWatchInterval
+	}
+	if watch.StableFor <= 0 {
+		watch.StableFor = defaultWatchStableFor
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	dir, err := filepath.Abs(watch.Dir)
+	if err != nil {
+		return fmt.Errorf("error converting watch folder %s to absolute: %w", watch.Dir, err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("watch folder %s is not a readable directory", dir)
+	}
+	if watch.MoveTo != "" {
+		if err := os.MkdirAll(watch.MoveTo, 0755); err != nil {
+			return fmt.Errorf("error creating %s: %w", watch.MoveTo, err)
+		}
+	}
+	statePath := filepath.Join(dir, watchStateFile)
+	processed, err := loadWatchState(statePath)
+	if err != nil {
+		return err
+	}
+	// Several runs against the same key share one quota
+	if cfg.QuotaLimiter == nil {
+		if cfg.QuotaLimiter, err = NewQuotaLimiter(cfg.Quota); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Watching folder for new videos", "dir", dir, "interval", watch.Interval, "stable_for", watch.StableFor)
+	pending := map[string]*pendingFile{}
+	for {
+		for _, name := range scanWatchFolder(logger, dir, processed, pending, watch.StableFor) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			p := pending[name]
+			delete(pending, name)
+			path := filepath.Join(dir, name)
+			logger.Info("Summarizing new video", "path", path)
+			run := cfg
+			run.InputPath = path
+			results, err := SummarizeVideos(ctx, run)
+			if ctx.Err() != nil {
+				// Interrupted: leave the video unrecorded so it is summarized after a restart
+				return nil
+			}
+			record := watchedFile{Size: p.size, ModTime: p.modTime, ProcessedAt: time.Now()}
+			if err != nil {
+				record.Error = err.Error()
+				logger.Error("Error summarizing video", "path", path, "error", err)
+			}
+			processed[name] = record
+			if err == nil && watch.MoveTo != "" {
+				if moved, moveErr := moveProcessed(path, results, watch.MoveTo); moveErr != nil {
+					logger.Warn("Error moving processed video", "path", path, "error", moveErr)
+				} else {
+					logger.Info("Moved video and outputs", "path", path, "to", moved)
+					delete(processed, name)
+				}
+			}
+			if err := saveWatchState(statePath, processed); err != nil {
+				logger.Error("Error saving watch state", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watch.Interval):
+		}
+	}
+}
+
+// scanWatchFolder updates pending with the videos in dir and returns those whose size and
+// modification time have not changed for stableFor, in name order
+func scanWatchFolder(logger *slog.Logger, dir string, processed map[string]watchedFile, pending map[string]*pendingFile, stableFor time.Duration) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Warn("Error reading watch folder", "dir", dir, "error", err)
+		return nil
+	}
+	now := time.Now()
+	seen := map[string]bool{}
+	var ready []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Hidden files are usually partial downloads or editor temp files
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !IsVideoFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if done, ok := processed[name]; ok && done.Size == info.Size() && done.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		seen[name] = true
+		p, ok := pending[name]
+		if !ok || p.size != info.Size() || !p.modTime.Equal(info.ModTime()) {
+			if !ok {
+				logger.Debug("New video, waiting for it to stop changing", "path", filepath.Join(dir, name))
+			}
+			pending[name] = &pendingFile{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(p.since) >= stableFor {
+			ready = append(ready, name)
+		}
+	}
+	for name := range pending {
+		if !seen[name] {
+			delete(pending, name)
+		}
+	}
+	return ready
+}
+
+// moveProcessed moves a summarized video and the files written for it into dir, and returns the
+// video's new path. Nothing in dir is replaced: when a name is taken, e.g. by an earlier recording
+// with the same name, the files are numbered together, as talk_2.mp4 and talk_2_output.txt. The
+// outputs are moved first and the video last, so on an error the video is still in place.
+func moveProcessed(videoPath string, results []*Result, dir string) (string, error) {
+	var outputs []string
+	for _, r := range results {
+		f := r.Files
+		for _, path := range []string{f.Summary, f.AudioTranscript, f.Subtitles, f.VideoTranscript, f.ChunkSummaries, f.Report, f.Log} {
+			if _, err := os.Lstat(path); path != "" && !errors.Is(err, os.ErrNotExist) {
+				outputs = append(outputs, path)
+			}
+		}
+	}
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	n := 1
+	for !movedNamesFree(append(outputs, videoPath), base, n, dir) {
+		n++
+	}
+
+	var errs []error
+	for _, path := range outputs {
+		if err := os.Rename(path, filepath.Join(dir, movedName(path, base, n))); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return "", fmt.Errorf("error moving the outputs, the video was left in place: %w", err)
+	}
+	moved := filepath.Join(dir, movedName(videoPath, base, n))
+	if err := os.Rename(videoPath, moved); err != nil {
+		return "", fmt.Errorf("error moving the video, its outputs were moved to %s: %w", dir, err)
+	}
+	return moved, nil
+}
+
+// movedName is the name path gets in the destination folder: with n > 1, a name starting with
+// the video's base name gets _n after it
+func movedName(path, base string, n int) string {
+	name := filepath.Base(path)
+	if n > 1 && strings.HasPrefix(name, base) {
+		name = fmt.Sprintf("%s_%d%s", base, n, name[len(base):])
+	}
+	return name
+}
+
+func movedNamesFree(paths []string, base string, n int, dir string) bool {
+	for _, path := range paths {
+		if _, err := os.Lstat(filepath.Join(dir, movedName(path, base, n))); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func loadWatchState(path string) (map[string]watchedFile, error) {
+	processed := map[string]watchedFile{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return processed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading watch state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &processed); err != nil {
+		return nil, fmt.Errorf("error parsing watch state %s: %w", path, err)
+	}
+	return processed, nil
+}
+
+// saveWatchState writes the state through a temporary file, so a crash never leaves it half written
+func saveWatchState(path string, processed map[string]watchedFile) error {
+	data, err := json.MarshalIndent(processed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding watch state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing watch state %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error replacing watch state %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanWatchFolderWaitsForStableVideos(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	talk := filepath.Join(dir, "talk.mp4")
+	writeFile(t, talk, "first half")
+	writeFile(t, filepath.Join(dir, ".partial.mp4"), "downloading")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "not a video")
+	writeFile(t, filepath.Join(dir, "nested", "other.mp4"), "not directly in the folder")
+	processed := map[string]watchedFile{}
+	pending := map[string]*pendingFile{}
+	scan := func(stableFor time.Duration) []string {
+		return scanWatchFolder(logger, dir, processed, pending, stableFor)
+	}
+
+	// A new video is ready once a scan finds it unchanged for stableFor
+	if ready := scan(0); len(ready) != 0 {
+		t.Errorf("first scan returned %v, want the video to wait for a second look", ready)
+	}
+	if ready := scan(time.Hour); len(ready) != 0 {
+		t.Errorf("returned %v before it was stable for long enough", ready)
+	}
+	if ready := scan(0); !reflect.DeepEqual(ready, []string{"talk.mp4"}) {
+		t.Errorf("got %v, want the unchanged video", ready)
+	}
+	if len(pending) != 1 {
+		t.Errorf("pending holds %d files, want only the video", len(pending))
+	}
+
+	// A video still being written starts waiting again
+	writeFile(t, talk, "first half, second half")
+	if ready := scan(0); len(ready) != 0 {
+		t.Errorf("got %v for a video that changed", ready)
+	}
+	if ready := scan(0); !reflect.DeepEqual(ready, []string{"talk.mp4"}) {
+		t.Errorf("got %v, want the video once it stopped changing", ready)
+	}
+
+	// A processed video is skipped until it is replaced
+	info, err := os.Stat(talk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed["talk.mp4"] = watchedFile{Size: info.Size(), ModTime: info.ModTime()}
+	delete(pending, "talk.mp4")
+	if ready := scan(0); len(ready) != 0 || len(pending) != 0 {
+		t.Errorf("got %v and pending %v for a processed video", ready, pending)
+	}
+	writeFile(t, talk, "a new recording")
+	os.Chtimes(talk, time.Now(), info.ModTime().Add(time.Minute))
+	scan(0)
+	if ready := scan(0); !reflect.DeepEqual(ready, []string{"talk.mp4"}) {
+		t.Errorf("got %v, want the replaced video", ready)
+	}
+
+	// A video removed while waiting is forgotten
+	writeFile(t, filepath.Join(dir, "gone.mp4"), "copied by mistake")
+	scan(0)
+	os.Remove(filepath.Join(dir, "gone.mp4"))
+	scan(0)
+	if _, ok := pending["gone.mp4"]; ok {
+		t.Error("a removed video is still pending")
+	}
+}
+
+func TestWatchStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), watchStateFile)
+	processed, err := loadWatchState(path)
+	if err != nil || len(processed) != 0 {
+		t.Fatalf("missing state file loaded %v, %v", processed, err)
+	}
+
+	now := time.Now()
+	want := map[string]watchedFile{
+		"talk.mp4":   {Size: 1 << 20, ModTime: now.Add(-time.Hour), ProcessedAt: now},
+		"broken.mkv": {Size: 12, ModTime: now.Add(-time.Minute), ProcessedAt: now, Error: "no video stream"},
+	}
+	if err := saveWatchState(path, want); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("the temporary state file was left behind")
+	}
+	got, err := loadWatchState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for name, w := range want {
+		g := got[name]
+		if g.Size != w.Size || !g.ModTime.Equal(w.ModTime) || !g.ProcessedAt.Equal(w.ProcessedAt) || g.Error != w.Error {
+			t.Errorf("%s: got %+v, want %+v", name, g, w)
+		}
+	}
+
+	writeFile(t, path, "{not json")
+	if _, err := loadWatchState(path); err == nil {
+		t.Error("a corrupt state file loaded without an error")
+	}
+}
+
+func TestMoveProcessed(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	video := filepath.Join(src, "talk.mp4")
+	writeFile(t, video, "new recording")
+	writeFile(t, filepath.Join(src, "talk_output.txt"), "new summary")
+	writeFile(t, filepath.Join(src, "talk_report.json"), "{}")
+	// An earlier recording with the same name was already moved
+	writeFile(t, filepath.Join(dst, "talk.mp4"), "earlier recording")
+	writeFile(t, filepath.Join(dst, "talk_output.txt"), "earlier summary")
+	results := []*Result{{VideoPath: video, Files: OutputFiles{
+		Summary:         filepath.Join(src, "talk_output.txt"),
+		Report:          filepath.Join(src, "talk_report.json"),
+		AudioTranscript: filepath.Join(src, "talk_audio_output.txt"), // not written
+	}}}
+
+	moved, err := moveProcessed(video, results, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dst, "talk_2.mp4"); moved != want {
+		t.Errorf("video moved to %s, want %s", moved, want)
+	}
+	for name, data := range map[string]string{
+		"talk.mp4": "earlier recording", "talk_output.txt": "earlier summary",
+		"talk_2.mp4": "new recording", "talk_2_output.txt": "new summary", "talk_2_report.json": "{}",
+	} {
+		if got, err := os.ReadFile(filepath.Join(dst, name)); err != nil || string(got) != data {
+			t.Errorf("%s holds %q, %v; want %q", name, got, err, data)
+		}
+	}
+	if entries, _ := os.ReadDir(src); len(entries) != 0 {
+		t.Errorf("%d files left behind", len(entries))
+	}
+}
+
+func TestMoveProcessedKeepsVideoWhenOutputsFail(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	video := filepath.Join(src, "talk.mp4")
+	writeFile(t, video, "recording")
+	writeFile(t, filepath.Join(src, "talk_output.txt"), "summary")
+	// The OS refuses a name with a NUL byte, so this output cannot be moved
+	results := []*Result{{VideoPath: video, Files: OutputFiles{
+		Summary: filepath.Join(src, "talk_output.txt"),
+		Report:  filepath.Join(src, "talk_report\x00.json"),
+	}}}
+
+	_, err := moveProcessed(video, results, dst)
+	if err == nil || !strings.Contains(err.Error(), "video was left in place") {
+		t.Fatalf("got %v, want an error saying the video stayed", err)
+	}
+	if _, err := os.Stat(video); err != nil {
+		t.Errorf("the video was moved although its outputs were not: %v", err)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for a logger writing from another goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchFolderRecordsFailedVideos(t *testing.T) {
+	dir, moveTo := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(dir, "talk.mp4"), "recording")
+	var logs syncBuffer
+	// An unknown transcription mode makes every run fail at once
+	cfg := SummaryConfig{VideoTranscription: "bogus", Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	watch := WatchConfig{Dir: dir, Interval: 5 * time.Millisecond, StableFor: 10 * time.Millisecond, MoveTo: moveTo}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- WatchFolder(ctx, cfg, watch) }()
+	statePath := filepath.Join(dir, watchStateFile)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if processed, _ := loadWatchState(statePath); len(processed) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("no video was recorded; log:\n%s", logs.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Later scans leave the recorded video alone
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("WatchFolder: %v", err)
+	}
+
+	processed, err := loadWatchState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, ok := processed["talk.mp4"]
+	if !ok || record.Size != int64(len("recording")) || !strings.Contains(record.Error, "bogus") {
+		t.Errorf("got state %+v", processed)
+	}
+	if n := strings.Count(logs.String(), "Summarizing new video"); n != 1 {
+		t.Errorf("video summarized %d times, want 1", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "talk.mp4")); err != nil {
+		t.Errorf("a failed video was moved: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package videoSummaryGo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// whisperSampleRate is the sample rate whisper.cpp expects; chunkVideo resamples audio chunks to it
+const whisperSampleRate = 16000
+
+// readWAVSamples decodes a 16-bit PCM WAV file into mono float32 samples in [-1, 1],
+// the input format of the whisper.cpp bindings. Multi-channel audio is downmixed.
+func readWAVSamples(path string) ([]float32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading wav file %s: %w", path, err)
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%s is not a RIFF/WAVE file", path)
+	}
+
+	var channels, bitsPerSample uint16
+	var sampleRate uint32
+	var pcm []byte
+	for offset := 12; offset+8 <= len(data); {
+		chunkID := string(data[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+		end := body + chunkSize
+		if end > len(data) {
+			// ffmpeg writes a placeholder size when the output is not seekable; take what is there
+			end = len(data)
+		}
+		switch chunkID {
+		case "fmt ":
+			if end-body < 16 {
+				return nil, fmt.Errorf("%s has a truncated fmt chunk", path)
+			}
+			if format := binary.LittleEndian.Uint16(data[body : body+2]); format != 1 {
+				return nil, fmt.Errorf("%s is not PCM (format %d)", path, format)
+			}
+			channels = binary.LittleEndian.Uint16(data[body+2 : body+4])
+			sampleRate = binary.LittleEndian.Uint32(data[body+4 : body+8])
+			bitsPerSample = binary.LittleEndian.Uint16(data[body+14 : body+16])
+		case "data":
+			pcm = data[body:end]
+		}
+		offset = end + chunkSize%2 // chunks are word aligned
+	}
+
+	if channels == 0 || pcm == nil {
+		return nil, fmt.Errorf("%s is missing a fmt or data chunk", path)
+	}
+	if bitsPerSample != 16 {
+		return nil, fmt.Errorf("%s has %d-bit samples, expected 16-bit", path, bitsPerSample)
+	}
+	if sampleRate != whisperSampleRate {
+		return nil, fmt.Errorf("%s is sampled at %d Hz, expected %d Hz", path, sampleRate, whisperSampleRate)
+	}
+
+	frameSize := int(channels) * 2
+	samples := make([]float32, len(pcm)/frameSize)
+	for i := range samples {
+		var sum float32
+		for c := 0; c < int(channels); c++ {
+			pos := i*frameSize + c*2
+			sum += float32(int16(binary.LittleEndian.Uint16(pcm[pos:pos+2]))) / 32768
+		}
+		samples[i] = sum / float32(channels)
+	}
+	return samples, nil
+}
@@ -0,0 +1,101 @@
+package videoSummaryGo
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// riffChunk encodes a RIFF chunk, padded to an even length
+func riffChunk(id string, body []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(body)))
+	chunk = append(chunk, body...)
+	if len(body)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func fmtChunk(format, channels uint16, sampleRate uint32, bitsPerSample uint16) []byte {
+	body := binary.LittleEndian.AppendUint16(nil, format)
+	body = binary.LittleEndian.AppendUint16(body, channels)
+	body = binary.LittleEndian.AppendUint32(body, sampleRate)
+	body = binary.LittleEndian.AppendUint32(body, sampleRate*uint32(channels*bitsPerSample/8))
+	body = binary.LittleEndian.AppendUint16(body, channels*bitsPerSample/8)
+	body = binary.LittleEndian.AppendUint16(body, bitsPerSample)
+	return riffChunk("fmt ", body)
+}
+
+func pcm16(samples ...int16) []byte {
+	var data []byte
+	for _, s := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(s))
+	}
+	return data
+}
+
+func wavFile(chunks ...[]byte) []byte {
+	var body []byte
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	data := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(4+len(body)))
+	return append(append(data, "WAVE"...), body...)
+}
+
+func TestReadWAVSamples(t *testing.T) {
+	mono := fmtChunk(1, 1, whisperSampleRate, 16)
+	// A data chunk whose size is a placeholder larger than the file, as ffmpeg writes to pipes
+	truncatedData := append(binary.LittleEndian.AppendUint32([]byte("data"), 0xFFFFFFFF), pcm16(16384, -16384)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []float32
+		wantErr string
+	}{
+		{"mono", wavFile(mono, riffChunk("data", pcm16(0, 16384, -32768))), []float32{0, 0.5, -1}, ""},
+		{"stereo downmix", wavFile(fmtChunk(1, 2, whisperSampleRate, 16), riffChunk("data", pcm16(16384, 0, -16384, -16384))), []float32{0.25, -0.5}, ""},
+		{"odd-sized chunk before data", wavFile(mono, riffChunk("LIST", []byte("abc")), riffChunk("data", pcm16(16384))), []float32{0.5}, ""},
+		{"truncated data chunk", wavFile(mono, truncatedData), []float32{0.5, -0.5}, ""},
+		{"partial trailing frame", wavFile(mono, riffChunk("data", append(pcm16(16384), 0x01))), []float32{0.5}, ""},
+		{"not RIFF", []byte("OggS\x00\x00\x00\x00WAVE"), nil, "not a RIFF/WAVE file"},
+		{"too short", []byte("RIFF"), nil, "not a RIFF/WAVE file"},
+		{"truncated fmt", wavFile(riffChunk("fmt ", []byte{1, 0, 1, 0})), nil, "truncated fmt chunk"},
+		{"float samples", wavFile(fmtChunk(3, 1, whisperSampleRate, 32), riffChunk("data", make([]byte, 8))), nil, "not PCM (format 3)"},
+		{"8-bit samples", wavFile(fmtChunk(1, 1, whisperSampleRate, 8), riffChunk("data", []byte{128})), nil, "8-bit samples"},
+		{"wrong sample rate", wavFile(fmtChunk(1, 1, 44100, 16), riffChunk("data", pcm16(0))), nil, "44100 Hz"},
+		{"missing data", wavFile(mono), nil, "missing a fmt or data chunk"},
+		{"missing fmt", wavFile(riffChunk("data", pcm16(0))), nil, "missing a fmt or data chunk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "chunk.wav")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readWAVSamples(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWAVSamplesMissingFile(t *testing.T) {
+	if _, err := readWAVSamples(filepath.Join(t.TempDir(), "missing.wav")); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
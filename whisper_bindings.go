@@ -0,0 +1,97 @@
+//go:build whisper
+
+package videoSummaryGo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// whisperBindingsTranscriber runs whisper.cpp in-process through its Go bindings.
+// The model is loaded once and shared by every chunk worker; whisper_full is not safe to
+// run concurrently on one model, so calls are serialized.
+type whisperBindingsTranscriber struct {
+	model     whisper.Model
+	threads   uint
+	language  string
+	translate bool
+
+	mu sync.Mutex
+}
+
+func newWhisperBindingsTranscriber(modelPath string, threads int, language string, translate bool) (AudioTranscriber, error) {
+	slog.Info("Loading whisper model", "path", modelPath)
+	startTime := time.Now()
+	model, err := whisper.New(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading whisper model %s: %w", modelPath, err)
+	}
+	slog.Info("Whisper model loaded", "duration", time.Since(startTime))
+	return &whisperBindingsTranscriber{model: model, threads: uint(threads), language: language, translate: translate}, nil
+}
+
+func (t *whisperBindingsTranscriber) TranscribeAudio(ctx context.Context, audioPath string, videoIndex int, chunkNum int) (AudioTranscript, error) {
+	samples, err := readWAVSamples(audioPath)
+	if err != nil {
+		return AudioTranscript{}, fmt.Errorf("error reading audio for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wctx, err := t.model.NewContext()
+	if err != nil {
+		return AudioTranscript{}, fmt.Errorf("error creating whisper context for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	if t.threads > 0 {
+		wctx.SetThreads(t.threads)
+	}
+	if t.language != "" {
+		if err := wctx.SetLanguage(t.language); err != nil {
+			return AudioTranscript{}, fmt.Errorf("error setting whisper language %q: %w", t.language, err)
+		}
+	}
+	wctx.SetTranslate(t.translate)
+
+	logFrom(ctx).Debug("Starting in-process whisper", "audio_path", audioPath)
+	startTime := time.Now()
+	// Returning false from the encoder-begin callback aborts whisper once the run is cancelled
+	encoderBegin := func() bool { return ctx.Err() == nil }
+	if err := wctx.Process(samples, encoderBegin, nil, nil); err != nil {
+		if ctx.Err() != nil {
+			return AudioTranscript{}, ctx.Err()
+		}
+		return AudioTranscript{}, fmt.Errorf("error running whisper for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+
+	var segments []TranscriptSegment
+	for {
+		segment, err := wctx.NextSegment()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return AudioTranscript{}, fmt.Errorf("error reading whisper segments for video %d chunk %d: %w", videoIndex, chunkNum, err)
+		}
+		segments = append(segments, TranscriptSegment{Start: segment.Start, End: segment.End, Text: strings.TrimSpace(segment.Text)})
+	}
+	logFrom(ctx).Debug("Whisper finished", "duration", time.Since(startTime))
+
+	language := t.language
+	if language == AutoDetectLanguage {
+		language = wctx.DetectedLanguage()
+	}
+	return AudioTranscript{Language: language, Segments: segments}, nil
+}
+
+func (t *whisperBindingsTranscriber) Close() error {
+	return t.model.Close()
+}
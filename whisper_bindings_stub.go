@@ -0,0 +1,11 @@
+//go:build !whisper
+
+package videoSummaryGo
+
+import "fmt"
+
+// newWhisperBindingsTranscriber is only available when built with -tags whisper against the
+// whisper.cpp submodule (see `make build`).
+func newWhisperBindingsTranscriber(modelPath string, threads int, language string, translate bool) (AudioTranscriber, error) {
+	return nil, fmt.Errorf("whisper backend %q is not available: rebuild with -tags whisper (make build)", WhisperBackendBindings)
+}
@@ -0,0 +1,200 @@
+package videoSummaryGo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	openAITranscriptionsPath = "/v1/audio/transcriptions"
+	openAITranslationsPath   = "/v1/audio/translations"
+)
+
+// whisperServerTimeout bounds a single chunk upload + transcription on a shared server
+const whisperServerTimeout = 15 * time.Minute
+
+// whisperServerTranscriber posts chunk WAVs to a whisper.cpp `server` /inference endpoint or to an
+// OpenAI-compatible /v1/audio/transcriptions endpoint. Both accept the same multipart form and
+// return the same verbose_json shape, so one implementation serves either.
+type whisperServerTranscriber struct {
+	endpoint   string
+	apiKey     string
+	model      string
+	language   string
+	translate  bool
+	diarize    string
+	httpClient *http.Client
+}
+
+// whisperServerResponse is the verbose_json response of both server flavours
+type whisperServerResponse struct {
+	Text     string `json:"text"`
+	Language string `json:"language"`
+	Error    string `json:"error"`
+	Segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Text  string  `json:"text"`
+	} `json:"segments"`
+}
+
+// newWhisperServerTranscriber returns a transcriber for endpoint. model is only needed by
+// OpenAI-compatible servers and apiKey is sent as a bearer token when set. A nil httpClient
+// gets a client with whisperServerTimeout.
+//
+// With translate set, whisper.cpp servers get translate=true, and OpenAI-compatible
+// transcription endpoints are swapped for their /v1/audio/translations sibling. OpenAI-compatible
+// servers cannot diarize, so asking for it is an error rather than a field they would ignore.
+func newWhisperServerTranscriber(endpoint string, apiKey string, model string, language string, translate bool, diarize string, httpClient *http.Client) (*whisperServerTranscriber, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("whisper backend %q needs a server URL", WhisperBackendServer)
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: whisperServerTimeout}
+	}
+	if translate && strings.HasSuffix(endpoint, openAITranscriptionsPath) {
+		endpoint = strings.TrimSuffix(endpoint, openAITranscriptionsPath) + openAITranslationsPath
+	}
+	if diarize != "" && isOpenAIEndpoint(endpoint) {
+		return nil, fmt.Errorf("diarization %q is not supported by OpenAI-compatible whisper servers", diarize)
+	}
+	return &whisperServerTranscriber{
+		endpoint:   endpoint,
+		apiKey:     apiKey,
+		model:      model,
+		language:   language,
+		translate:  translate,
+		diarize:    diarize,
+		httpClient: httpClient,
+	}, nil
+}
+
+func (t *whisperServerTranscriber) TranscribeAudio(ctx context.Context, audioPath string, videoIndex int, chunkNum int) (AudioTranscript, error) {
+	body, contentType, err := t.buildRequestBody(audioPath)
+	if err != nil {
+		return AudioTranscript{}, fmt.Errorf("error building whisper server request for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, body)
+	if err != nil {
+		return AudioTranscript{}, fmt.Errorf("error creating whisper server request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	logFrom(ctx).Debug("Sending audio to whisper server", "endpoint", t.endpoint)
+	startTime := time.Now()
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return AudioTranscript{}, fmt.Errorf("error calling whisper server for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AudioTranscript{}, fmt.Errorf("error reading whisper server response for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	logFrom(ctx).Debug("Whisper server finished", "duration", time.Since(startTime))
+
+	if resp.StatusCode != http.StatusOK {
+		return AudioTranscript{}, fmt.Errorf("whisper server returned %s for video %d chunk %d: %s", resp.Status, videoIndex, chunkNum, strings.TrimSpace(string(respBody)))
+	}
+
+	var parsed whisperServerResponse
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		return AudioTranscript{}, fmt.Errorf("error decoding whisper server response for video %d chunk %d: %w", videoIndex, chunkNum, err)
+	}
+	if parsed.Error != "" {
+		return AudioTranscript{}, fmt.Errorf("whisper server error for video %d chunk %d: %s", videoIndex, chunkNum, parsed.Error)
+	}
+
+	transcript := AudioTranscript{Language: t.language}
+	if t.language == "" || t.language == AutoDetectLanguage {
+		// verbose_json reports the detected language; OpenAI spells it out ("english")
+		transcript.Language = parsed.Language
+	}
+
+	if len(parsed.Segments) == 0 {
+		if text := strings.TrimSpace(parsed.Text); text != "" {
+			transcript.Segments = []TranscriptSegment{{Text: text}}
+		}
+		return transcript, nil
+	}
+	segments := make([]TranscriptSegment, 0, len(parsed.Segments))
+	for _, seg := range parsed.Segments {
+		segments = append(segments, TranscriptSegment{
+			Start: time.Duration(seg.Start * float64(time.Second)),
+			End:   time.Duration(seg.End * float64(time.Second)),
+			Text:  strings.TrimSpace(seg.Text),
+		})
+	}
+	transcript.Segments = applySpeakerMarkers(segments, t.diarize)
+	return transcript, nil
+}
+
+func (t *whisperServerTranscriber) buildRequestBody(audioPath string) (io.Reader, string, error) {
+	audioFile, err := os.Open(audioPath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer audioFile.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, audioFile); err != nil {
+		return nil, "", err
+	}
+
+	fields := map[string]string{"response_format": "verbose_json"}
+	if t.model != "" {
+		fields["model"] = t.model
+	}
+	openAI := isOpenAIEndpoint(t.endpoint)
+	// whisper.cpp servers understand "auto"; OpenAI-compatible ones detect by omission, and
+	// their translations endpoint takes no language at all
+	if t.language != "" && !(openAI && (t.language == AutoDetectLanguage || t.translate)) {
+		fields["language"] = t.language
+	}
+	// OpenAI-compatible servers translate through the endpoint and reject unknown fields
+	if !openAI {
+		if t.translate {
+			fields["translate"] = "true"
+		}
+		switch t.diarize {
+		case DiarizationTinydiarize:
+			fields["tinydiarize"] = "true"
+		case DiarizationStereo:
+			fields["diarize"] = "true"
+		}
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+func isOpenAIEndpoint(endpoint string) bool {
+	return strings.HasSuffix(endpoint, openAITranscriptionsPath) || strings.HasSuffix(endpoint, openAITranslationsPath)
+}
+
+func (t *whisperServerTranscriber) Close() error { return nil }
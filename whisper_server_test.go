@@ -0,0 +1,208 @@
+package videoSummaryGo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// whisperRequest is what a fake whisper server received
+type whisperRequest struct {
+	path          string
+	authorization string
+	fileName      string
+	fileData      string
+	fields        map[string]string
+}
+
+// fakeWhisperServer records each request and answers with status and body
+func fakeWhisperServer(t *testing.T, status int, body string) (*httptest.Server, *whisperRequest) {
+	t.Helper()
+	got := &whisperRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got method %s, want POST", r.Method)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("request is not a multipart form: %v", err)
+		} else {
+			got.path, got.authorization = r.URL.Path, r.Header.Get("Authorization")
+			got.fields = map[string]string{}
+			for name, values := range r.MultipartForm.Value {
+				got.fields[name] = strings.Join(values, ",")
+			}
+			if files := r.MultipartForm.File["file"]; len(files) == 1 {
+				got.fileName = files[0].Filename
+				f, _ := files[0].Open()
+				data, _ := io.ReadAll(f)
+				f.Close()
+				got.fileData = string(data)
+			}
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, got
+}
+
+func writeTestAudio(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "talk_chunk_3.wav")
+	if err := os.WriteFile(path, []byte("RIFF fake wav data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWhisperServerInference(t *testing.T) {
+	server, got := fakeWhisperServer(t, http.StatusOK, `{
+		"text": "hello there general",
+		"language": "en",
+		"segments": [
+			{"start": 0, "end": 1.5, "text": " (speaker 0) hello there"},
+			{"start": 1.5, "end": 3.25, "text": " (speaker 1) general"}
+		]
+	}`)
+	t.Setenv("WHISPER_SERVER_API_KEY", "secret")
+	cfg := &SummaryConfig{
+		WhisperBackend:      WhisperBackendServer,
+		WhisperServerURL:    server.URL + "/inference",
+		WhisperServerAPIKey: os.Getenv("WHISPER_SERVER_API_KEY"),
+		WhisperLanguage:     AutoDetectLanguage,
+		Diarization:         DiarizationStereo,
+	}
+	transcriber, err := newAudioTranscriber(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audioPath := writeTestAudio(t)
+	transcript, err := transcriber.TranscribeAudio(context.Background(), audioPath, 1, 3)
+	if err != nil {
+		t.Fatalf("TranscribeAudio: %v", err)
+	}
+
+	if got.path != "/inference" || got.authorization != "Bearer secret" {
+		t.Errorf("got path %q and Authorization %q", got.path, got.authorization)
+	}
+	if got.fileName != "talk_chunk_3.wav" || got.fileData != "RIFF fake wav data" {
+		t.Errorf("got file %q with %q", got.fileName, got.fileData)
+	}
+	wantFields := map[string]string{"response_format": "verbose_json", "language": "auto", "diarize": "true"}
+	if !reflect.DeepEqual(got.fields, wantFields) {
+		t.Errorf("got fields %v, want %v", got.fields, wantFields)
+	}
+
+	want := AudioTranscript{Language: "en", Segments: []TranscriptSegment{
+		{Start: 0, End: 1500 * time.Millisecond, Text: "hello there", Speaker: "Speaker 1"},
+		{Start: 1500 * time.Millisecond, End: 3250 * time.Millisecond, Text: "general", Speaker: "Speaker 2"},
+	}}
+	if !reflect.DeepEqual(transcript, want) {
+		t.Errorf("got %+v, want %+v", transcript, want)
+	}
+}
+
+func TestWhisperServerOpenAITranslation(t *testing.T) {
+	server, got := fakeWhisperServer(t, http.StatusOK, `{"text": " Hello world. ", "language": "german"}`)
+	transcriber, err := newWhisperServerTranscriber(server.URL+openAITranscriptionsPath, "", "whisper-1", AutoDetectLanguage, true, "", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript, err := transcriber.TranscribeAudio(context.Background(), writeTestAudio(t), 1, 1)
+	if err != nil {
+		t.Fatalf("TranscribeAudio: %v", err)
+	}
+	if got.path != openAITranslationsPath {
+		t.Errorf("posted to %s, want %s", got.path, openAITranslationsPath)
+	}
+	if got.authorization != "" {
+		t.Errorf("sent Authorization %q without an API key", got.authorization)
+	}
+	// OpenAI-compatible servers detect the language when it is left out, and translate by endpoint
+	wantFields := map[string]string{"response_format": "verbose_json", "model": "whisper-1"}
+	if !reflect.DeepEqual(got.fields, wantFields) {
+		t.Errorf("got fields %v, want %v", got.fields, wantFields)
+	}
+	want := AudioTranscript{Language: "german", Segments: []TranscriptSegment{{Text: "Hello world."}}}
+	if !reflect.DeepEqual(transcript, want) {
+		t.Errorf("got %+v, want %+v", transcript, want)
+	}
+}
+
+func TestWhisperServerOpenAIFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		language  string
+		translate bool
+		want      map[string]string
+	}{
+		{"language", "de", false, map[string]string{"response_format": "verbose_json", "model": "whisper-1", "language": "de"}},
+		// The translations endpoint takes no language
+		{"translation with language", "de", true, map[string]string{"response_format": "verbose_json", "model": "whisper-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, got := fakeWhisperServer(t, http.StatusOK, `{"text": "Hallo"}`)
+			transcriber, err := newWhisperServerTranscriber(server.URL+openAITranscriptionsPath, "", "whisper-1", tt.language, tt.translate, "", server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := transcriber.TranscribeAudio(context.Background(), writeTestAudio(t), 1, 1); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.fields, tt.want) {
+				t.Errorf("got fields %v, want %v", got.fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhisperServerOpenAIDiarization(t *testing.T) {
+	for _, endpoint := range []string{"http://localhost" + openAITranscriptionsPath, "http://localhost" + openAITranslationsPath} {
+		if _, err := newWhisperServerTranscriber(endpoint, "", "whisper-1", "en", false, DiarizationStereo, nil); err == nil {
+			t.Errorf("%s accepted diarization", endpoint)
+		}
+	}
+	if _, err := newWhisperServerTranscriber("http://localhost/inference", "", "", "en", false, DiarizationTinydiarize, nil); err != nil {
+		t.Errorf("whisper.cpp server refused diarization: %v", err)
+	}
+}
+
+func TestWhisperServerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"status", http.StatusServiceUnavailable, "model is loading\n", "503 Service Unavailable for video 2 chunk 4: model is loading"},
+		{"error field", http.StatusOK, `{"error": "failed to read WAV"}`, "whisper server error for video 2 chunk 4: failed to read WAV"},
+		{"bad json", http.StatusOK, "<html>", "error decoding whisper server response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := fakeWhisperServer(t, tt.status, tt.body)
+			transcriber, err := newWhisperServerTranscriber(server.URL+"/inference", "secret", "", "en", false, "", server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = transcriber.TranscribeAudio(context.Background(), writeTestAudio(t), 2, 4)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWhisperServerTranscriberNeedsURL(t *testing.T) {
+	if _, err := newWhisperServerTranscriber("", "", "", "", false, "", nil); err == nil {
+		t.Error("accepted an empty server URL")
+	}
+}